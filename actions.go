@@ -0,0 +1,233 @@
+package main
+
+// Action is the name of something a key can be bound to.
+type Action string
+
+const (
+	// Unbinds a key.
+	ACTION_IGNORE               Action = "ignore"
+	ACTION_ACCEPT               Action = "accept"
+	ACTION_ACCEPT_PAGER         Action = "accept-pager"
+	ACTION_ACCEPT_PRINT_PATH    Action = "accept-print-path"
+	ACTION_ACCEPT_COPY_PATH     Action = "accept-copy-path"
+	ACTION_ACCEPT_COPY_CONTENTS Action = "accept-copy-contents"
+	ACTION_ABORT                Action = "abort"
+	ACTION_SELECT_PREVIOUS      Action = "select-prev"
+	ACTION_SELECT_NEXT          Action = "select-next"
+	ACTION_PAGE_UP              Action = "page-up"
+	ACTION_PAGE_DOWN            Action = "page-down"
+	ACTION_FIRST                Action = "first"
+	ACTION_LAST                 Action = "last"
+	ACTION_HOME                 Action = "home"
+	ACTION_END                  Action = "end"
+	ACTION_TOGGLE_RELATED       Action = "toggle-related"
+	ACTION_TOGGLE_MARK_NEXT     Action = "toggle-mark-next"
+	ACTION_TOGGLE_MARK_PREVIOUS Action = "toggle-mark-prev"
+	ACTION_MENU                 Action = "menu"
+	ACTION_TOGGLE_PREVIEW       Action = "toggle-preview"
+	ACTION_PREVIEW_UP           Action = "preview-up"
+	ACTION_PREVIEW_DOWN         Action = "preview-down"
+	ACTION_PREVIEW_PAGE_UP      Action = "preview-page-up"
+	ACTION_PREVIEW_PAGE_DOWN    Action = "preview-page-down"
+	ACTION_BACKWARD_CHAR        Action = "backward-char"
+	ACTION_FORWARD_CHAR         Action = "forward-char"
+	ACTION_BEGINNING_OF_LINE    Action = "beginning-of-line"
+	ACTION_END_OF_LINE          Action = "end-of-line"
+	ACTION_BACKWARD_WORD        Action = "backward-word"
+	ACTION_FORWARD_WORD         Action = "forward-word"
+	ACTION_BACKWARD_DELETE_CHAR Action = "backward-delete-char"
+	ACTION_DELETE_CHAR          Action = "delete-char"
+	ACTION_BACKWARD_DELETE_WORD Action = "backward-delete-word"
+	ACTION_DELETE_WORD          Action = "delete-word"
+	ACTION_BACKWARD_KILL_LINE   Action = "backward-kill-line"
+	ACTION_KILL_LINE            Action = "kill-line"
+	ACTION_YANK                 Action = "yank"
+)
+
+var ACTIONS = []Action{
+	ACTION_IGNORE,
+	ACTION_ACCEPT,
+	ACTION_ACCEPT_PAGER,
+	ACTION_ACCEPT_PRINT_PATH,
+	ACTION_ACCEPT_COPY_PATH,
+	ACTION_ACCEPT_COPY_CONTENTS,
+	ACTION_ABORT,
+	ACTION_SELECT_PREVIOUS,
+	ACTION_SELECT_NEXT,
+	ACTION_PAGE_UP,
+	ACTION_PAGE_DOWN,
+	ACTION_FIRST,
+	ACTION_LAST,
+	ACTION_HOME,
+	ACTION_END,
+	ACTION_TOGGLE_RELATED,
+	ACTION_TOGGLE_MARK_NEXT,
+	ACTION_TOGGLE_MARK_PREVIOUS,
+	ACTION_MENU,
+	ACTION_TOGGLE_PREVIEW,
+	ACTION_PREVIEW_UP,
+	ACTION_PREVIEW_DOWN,
+	ACTION_PREVIEW_PAGE_UP,
+	ACTION_PREVIEW_PAGE_DOWN,
+	ACTION_BACKWARD_CHAR,
+	ACTION_FORWARD_CHAR,
+	ACTION_BEGINNING_OF_LINE,
+	ACTION_END_OF_LINE,
+	ACTION_BACKWARD_WORD,
+	ACTION_FORWARD_WORD,
+	ACTION_BACKWARD_DELETE_CHAR,
+	ACTION_DELETE_CHAR,
+	ACTION_BACKWARD_DELETE_WORD,
+	ACTION_DELETE_WORD,
+	ACTION_BACKWARD_KILL_LINE,
+	ACTION_KILL_LINE,
+	ACTION_YANK,
+}
+
+// EDITING_ACTIONS edit the query, or the prompt of the menu when it is open.
+var EDITING_ACTIONS = map[Action]func(le *LineEditor){
+	ACTION_BACKWARD_CHAR:        (*LineEditor).Left,
+	ACTION_FORWARD_CHAR:         (*LineEditor).Right,
+	ACTION_BEGINNING_OF_LINE:    (*LineEditor).Home,
+	ACTION_END_OF_LINE:          (*LineEditor).End,
+	ACTION_BACKWARD_WORD:        (*LineEditor).WordBackward,
+	ACTION_FORWARD_WORD:         (*LineEditor).WordForward,
+	ACTION_BACKWARD_DELETE_CHAR: (*LineEditor).Backspace,
+	ACTION_DELETE_CHAR:          (*LineEditor).Delete,
+	ACTION_BACKWARD_DELETE_WORD: (*LineEditor).KillFieldBackward,
+	ACTION_DELETE_WORD:          (*LineEditor).KillWordForward,
+	ACTION_BACKWARD_KILL_LINE:   (*LineEditor).KillToStart,
+	ACTION_KILL_LINE:            (*LineEditor).KillToEnd,
+	ACTION_YANK:                 (*LineEditor).Yank,
+}
+
+func isAction(action Action) bool {
+	for _, a := range ACTIONS {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+func (im *InputManager) perform(action Action) {
+	Logger.Print("Performing ", action)
+	search := im.Options.Search
+	if f, ok := EDITING_ACTIONS[action]; ok {
+		search.Edit(f)
+		return
+	}
+	switch action {
+	case ACTION_ACCEPT:
+		im.accept(OPEN_EDITOR)
+	case ACTION_ACCEPT_PAGER:
+		im.accept(OPEN_PAGER)
+	case ACTION_ACCEPT_PRINT_PATH:
+		im.accept(OPEN_PRINT_PATH)
+	case ACTION_ACCEPT_COPY_PATH:
+		im.accept(OPEN_COPY_PATH)
+	case ACTION_ACCEPT_COPY_CONTENTS:
+		im.accept(OPEN_COPY_CONTENTS)
+	case ACTION_ABORT:
+		im.Options.Abort <- struct{}{}
+	case ACTION_SELECT_PREVIOUS:
+		search.SelectPrevious()
+	case ACTION_SELECT_NEXT:
+		search.SelectNext()
+	case ACTION_PAGE_UP:
+		search.MoveSelection(-im.pageSize())
+	case ACTION_PAGE_DOWN:
+		search.MoveSelection(im.pageSize())
+	case ACTION_FIRST:
+		search.SelectFirst()
+	case ACTION_LAST:
+		search.SelectLast()
+	case ACTION_HOME:
+		// Move within the query when it is selected, otherwise within the
+		// results.
+		if selection, _ := search.Results(); selection == -1 {
+			search.Edit((*LineEditor).Home)
+		} else {
+			search.SelectFirst()
+		}
+	case ACTION_END:
+		if selection, _ := search.Results(); selection == -1 {
+			search.Edit((*LineEditor).End)
+		} else {
+			search.SelectLast()
+		}
+	case ACTION_TOGGLE_RELATED:
+		search.ToggleRelated()
+	case ACTION_TOGGLE_MARK_NEXT:
+		search.ToggleMark(1)
+	case ACTION_TOGGLE_MARK_PREVIOUS:
+		search.ToggleMark(-1)
+	case ACTION_MENU:
+		selection, results := search.Results()
+		if selection != -1 && !results[selection].Create {
+			im.Options.Menu.Open(results[selection].Title)
+		}
+	case ACTION_TOGGLE_PREVIEW:
+		im.Options.Preview.Toggle()
+	case ACTION_PREVIEW_UP:
+		im.Options.Preview.Scroll(-1)
+	case ACTION_PREVIEW_DOWN:
+		im.Options.Preview.Scroll(1)
+	case ACTION_PREVIEW_PAGE_UP:
+		im.Options.Preview.Scroll(-im.previewPageSize())
+	case ACTION_PREVIEW_PAGE_DOWN:
+		im.Options.Preview.Scroll(im.previewPageSize())
+	}
+}
+
+// accept opens the chosen notes as given by how, or creates a note, which is
+// always opened in the editor.
+func (im *InputManager) accept(how OpenMode) {
+	if title, ok := im.Options.Search.Creating(); ok {
+		im.Options.Menu.Create(title)
+		return
+	}
+	im.Options.Search.Select(how)
+}
+
+// performMenu performs an action while the menu is open. Moving and accepting
+// apply to the menu, editing to its prompt, and aborting closes it.
+func (im *InputManager) performMenu(action Action) {
+	Logger.Print("Performing ", action, " in menu")
+	menu := im.Options.Menu
+	if f, ok := EDITING_ACTIONS[action]; ok {
+		menu.Edit(f)
+		return
+	}
+	switch action {
+	case ACTION_ACCEPT:
+		menu.Accept()
+	case ACTION_ABORT, ACTION_MENU:
+		menu.Close()
+	case ACTION_SELECT_PREVIOUS:
+		menu.SelectPrevious()
+	case ACTION_SELECT_NEXT:
+		menu.SelectNext()
+	case ACTION_HOME:
+		menu.Edit((*LineEditor).Home)
+	case ACTION_END:
+		menu.Edit((*LineEditor).End)
+	}
+}
+
+// pageSize returns the number of results drawn at once, which is less than
+// the screen in a region or beside the preview pane.
+func (im *InputManager) pageSize() int {
+	if rows := im.Options.Draw.ResultRows(); rows > 0 {
+		return rows
+	}
+	return 1
+}
+
+// previewPageSize returns the number of lines of the preview pane drawn.
+func (im *InputManager) previewPageSize() int {
+	if rows := im.Options.Draw.PreviewRows(); rows > 0 {
+		return rows
+	}
+	return 1
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
 	"io"
 )
@@ -105,10 +106,15 @@ func (a ANSI) EL(n int) error {
 }
 
 // Select Graphic Rendition
-func (a ANSI) SGR(n int) error {
+func (a ANSI) SGR(parameters ...int) error {
 	var bb BytesBuilder
 	bb.WriteBytes(ESC, CSI)
-	bb.WriteInteger(n)
+	for i, n := range parameters {
+		if i > 0 {
+			bb.WriteBytes(';')
+		}
+		bb.WriteInteger(n)
+	}
 	bb.WriteBytes('m')
 	return bb.Build(a)
 }
@@ -146,3 +152,71 @@ func (a ANSI) DECTCEM(show bool) error {
 	bb.WriteBytes(ESC, CSI, '?', '2', '5', c)
 	return bb.Build(a)
 }
+
+// DEC Private Mode Set
+func (a ANSI) DECSET(n int) error {
+	var bb BytesBuilder
+	bb.WriteBytes(ESC, CSI, '?')
+	bb.WriteInteger(n)
+	bb.WriteBytes('h')
+	return bb.Build(a)
+}
+
+// DEC Private Mode Reset
+func (a ANSI) DECRST(n int) error {
+	var bb BytesBuilder
+	bb.WriteBytes(ESC, CSI, '?')
+	bb.WriteInteger(n)
+	bb.WriteBytes('l')
+	return bb.Build(a)
+}
+
+// Primary Device Attributes
+func (a ANSI) DA() error {
+	var bb BytesBuilder
+	bb.WriteBytes(ESC, CSI, 'c')
+	return bb.Build(a)
+}
+
+// Query the flags of the progressive enhancement keyboard protocol
+func (a ANSI) QueryKeyboardFlags() error {
+	var bb BytesBuilder
+	bb.WriteBytes(ESC, CSI, '?', 'u')
+	return bb.Build(a)
+}
+
+// Push flags of the progressive enhancement keyboard protocol
+func (a ANSI) PushKeyboardFlags(flags int) error {
+	var bb BytesBuilder
+	bb.WriteBytes(ESC, CSI, '>')
+	bb.WriteInteger(flags)
+	bb.WriteBytes('u')
+	return bb.Build(a)
+}
+
+// Pop flags of the progressive enhancement keyboard protocol
+func (a ANSI) PopKeyboardFlags() error {
+	var bb BytesBuilder
+	bb.WriteBytes(ESC, CSI, '<', 'u')
+	return bb.Build(a)
+}
+
+// Set the clipboard with Operating System Command 52
+func (a ANSI) OSC52(text string) error {
+	var bb BytesBuilder
+	bb.WriteBytes(ESC, ']', '5', '2', ';', 'c', ';')
+	bb.WriteBytes([]byte(base64.StdEncoding.EncodeToString([]byte(text)))...)
+	bb.WriteBytes(ESC, '\\')
+	return bb.Build(a)
+}
+
+// Cursor Position, starting at 1
+func (a ANSI) CUP(row, column int) error {
+	var bb BytesBuilder
+	bb.WriteBytes(ESC, CSI)
+	bb.WriteInteger(row)
+	bb.WriteBytes(';')
+	bb.WriteInteger(column)
+	bb.WriteBytes('H')
+	return bb.Build(a)
+}
@@ -0,0 +1,106 @@
+package main
+
+// Approximate matching kicks in when exact matching yields fewer results.
+const MIN_EXACT_RESULTS = 5
+
+// maxDistance returns the number of typos tolerated in a term.
+func maxDistance(term []rune) int {
+	switch {
+	case len(term) < 3:
+		return 0
+	case len(term) < 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// boundedDistance returns the edit distance between a and b, counting
+// insertions, deletions, substitutions and transpositions of adjacent
+// characters. Distances greater than k are reported as k + 1.
+func boundedDistance(a, b []rune, k int) int {
+	if len(a)-len(b) > k || len(b)-len(a) > k {
+		return k + 1
+	}
+	// Rows of the dynamic programming matrix, for the two previous prefixes
+	// of a and the current one.
+	previous2 := make([]int, len(b)+1)
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		rowMin := current[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d := previous[j-1] + cost
+			if previous[j]+1 < d {
+				d = previous[j] + 1
+			}
+			if current[j-1]+1 < d {
+				d = current[j-1] + 1
+			}
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] &&
+				previous2[j-2]+1 < d {
+				d = previous2[j-2] + 1
+			}
+			current[j] = d
+			if d < rowMin {
+				rowMin = d
+			}
+		}
+		if rowMin > k {
+			return k + 1
+		}
+		previous2, previous, current = previous, current, previous2
+	}
+	if previous[len(b)] > k {
+		return k + 1
+	}
+	return previous[len(b)]
+}
+
+// Approximate returns, for every note containing a close match of each term
+// of the query, the number of occurrences of those matches.
+func (idx *Index) Approximate(query string) map[string]int {
+	matches := map[string]int{}
+	queryTerms := tokenize(query)
+	if len(queryTerms) == 0 {
+		return matches
+	}
+	// Find the terms of the index close to each query term.
+	similar := make([]map[string]bool, len(queryTerms))
+	for i, queryTerm := range queryTerms {
+		q := []rune(queryTerm)
+		k := maxDistance(q)
+		similar[i] = map[string]bool{}
+		for term := range idx.frequencies {
+			if boundedDistance(q, []rune(term), k) <= k {
+				similar[i][term] = true
+			}
+		}
+	}
+	for title, counts := range idx.documents {
+		total := 0
+		for i := range queryTerms {
+			found := 0
+			for term := range similar[i] {
+				found += counts[term]
+			}
+			if found == 0 {
+				total = 0
+				break
+			}
+			total += found
+		}
+		if total > 0 {
+			matches[title] = total
+		}
+	}
+	return matches
+}
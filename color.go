@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Color depths, the number of colors a terminal supports, which are also the
+// kinds of colors.
+const (
+	COLOR_NONE = iota
+	COLOR_16
+	COLOR_256
+	COLOR_TRUECOLOR
+)
+
+// ColorDepth returns the color depth of the terminal as given by the
+// environment. Setting NO_COLOR disables colors.
+func ColorDepth() int {
+	if os.Getenv("NO_COLOR") != "" {
+		return COLOR_NONE
+	}
+	switch os.Getenv("COLORTERM") {
+	case "truecolor", "24bit":
+		return COLOR_TRUECOLOR
+	}
+	term := os.Getenv("TERM")
+	switch {
+	case term == "dumb":
+		return COLOR_NONE
+	case strings.Contains(term, "256color"):
+		return COLOR_256
+	}
+	return COLOR_16
+}
+
+// COLOR_NAMES are the names of the 16 basic colors, which are brighter when
+// prefixed by "bright-".
+var COLOR_NAMES = []string{
+	"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// PALETTE is the usual RGB values of the 16 basic colors, with which they are
+// compared to other colors.
+var PALETTE = [16][3]int{
+	{0x00, 0x00, 0x00}, {0xCD, 0x00, 0x00}, {0x00, 0xCD, 0x00},
+	{0xCD, 0xCD, 0x00}, {0x00, 0x00, 0xEE}, {0xCD, 0x00, 0xCD},
+	{0x00, 0xCD, 0xCD}, {0xE5, 0xE5, 0xE5}, {0x7F, 0x7F, 0x7F},
+	{0xFF, 0x00, 0x00}, {0x00, 0xFF, 0x00}, {0xFF, 0xFF, 0x00},
+	{0x5C, 0x5C, 0xFF}, {0xFF, 0x00, 0xFF}, {0x00, 0xFF, 0xFF},
+	{0xFF, 0xFF, 0xFF},
+}
+
+// Levels of each component of the 6x6x6 color cube of the 256 colors.
+var CUBE_LEVELS = [6]int{0x00, 0x5F, 0x87, 0xAF, 0xD7, 0xFF}
+
+// Color is one of the 16 basic colors, one of 256 indexed colors, or a 24-bit
+// RGB color.
+type Color struct {
+	Kind int
+	// The index of a basic or indexed color.
+	Index int
+	RGB   [3]int
+}
+
+// ParseColor parses a color such as "red", "bright-blue", "208" or
+// "#ff8700".
+func ParseColor(s string) (Color, error) {
+	name := strings.ToLower(s)
+	if name == "gray" || name == "grey" {
+		name = "bright-black"
+	}
+	bright := strings.HasPrefix(name, "bright-")
+	for i, n := range COLOR_NAMES {
+		if strings.TrimPrefix(name, "bright-") == n {
+			if bright {
+				i += 8
+			}
+			return Color{COLOR_16, i, PALETTE[i]}, nil
+		}
+	}
+	if strings.HasPrefix(name, "#") && len(name) == 7 {
+		n, err := strconv.ParseUint(name[1:], 16, 24)
+		if err == nil {
+			return Color{
+				COLOR_TRUECOLOR,
+				0,
+				[3]int{int(n >> 16), int(n >> 8 & 0xFF), int(n & 0xFF)}}, nil
+		}
+	}
+	if n, err := strconv.Atoi(name); err == nil && n >= 0 && n < 256 {
+		return Color{COLOR_256, n, indexedRGB(n)}, nil
+	}
+	return Color{}, fmt.Errorf("unknown color %q", s)
+}
+
+// indexedRGB returns the RGB value of one of the 256 indexed colors: the 16
+// basic colors, then the 6x6x6 color cube, then 24 shades of gray.
+func indexedRGB(n int) [3]int {
+	switch {
+	case n < 16:
+		return PALETTE[n]
+	case n < 232:
+		n -= 16
+		return [3]int{CUBE_LEVELS[n/36], CUBE_LEVELS[n/6%6], CUBE_LEVELS[n%6]}
+	}
+	gray := 8 + 10*(n-232)
+	return [3]int{gray, gray, gray}
+}
+
+func distance(a, b [3]int) int {
+	d := 0
+	for i := range a {
+		d += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return d
+}
+
+// nearest returns the index of the color closest to rgb among the indexed
+// colors from first up to but excluding last.
+func nearest(rgb [3]int, first, last int) int {
+	best := first
+	for n := first + 1; n < last; n++ {
+		if distance(indexedRGB(n), rgb) < distance(indexedRGB(best), rgb) {
+			best = n
+		}
+	}
+	return best
+}
+
+// Downgrade returns the closest color a terminal of the given depth supports.
+func (c Color) Downgrade(depth int) Color {
+	if c.Kind <= depth {
+		return c
+	}
+	switch depth {
+	case COLOR_256:
+		n := nearest(c.RGB, 16, 256)
+		return Color{COLOR_256, n, indexedRGB(n)}
+	case COLOR_16:
+		if c.Kind == COLOR_256 && c.Index < 16 {
+			return Color{COLOR_16, c.Index, c.RGB}
+		}
+		n := nearest(c.RGB, 0, 16)
+		return Color{COLOR_16, n, PALETTE[n]}
+	}
+	return Color{}
+}
+
+// parameters returns the SGR parameters setting the color as the foreground,
+// or as the background.
+func (c Color) parameters(background bool) []int {
+	base := 30
+	if background {
+		base = 40
+	}
+	switch c.Kind {
+	case COLOR_16:
+		if c.Index >= 8 {
+			return []int{base + 60 + c.Index - 8}
+		}
+		return []int{base + c.Index}
+	case COLOR_256:
+		return []int{base + 8, 5, c.Index}
+	case COLOR_TRUECOLOR:
+		return []int{base + 8, 2, c.RGB[0], c.RGB[1], c.RGB[2]}
+	}
+	return nil
+}
+
+// Style is the SGR parameters of an element of the screen.
+type Style []int
+
+// STYLE_ATTRIBUTES are the names of the attributes of styles, with their SGR
+// parameters.
+var STYLE_ATTRIBUTES = map[string]int{
+	"bold":          1,
+	"dim":           2,
+	"italic":        3,
+	"underline":     4,
+	"inverse":       7,
+	"reverse":       7,
+	"strikethrough": 9,
+}
+
+// ParseStyle parses a style such as "bold yellow on #303030": attributes, a
+// foreground color, and a background color after "on", downgraded to the
+// color depth of the terminal. The style "none" has no attributes or colors.
+func ParseStyle(s string, depth int) (Style, error) {
+	style := Style{}
+	background := false
+	for _, word := range strings.Fields(strings.ToLower(s)) {
+		if word == "none" {
+			continue
+		}
+		if word == "on" {
+			background = true
+			continue
+		}
+		if n, ok := STYLE_ATTRIBUTES[word]; ok && !background {
+			style = append(style, n)
+			continue
+		}
+		color, err := ParseColor(word)
+		if err != nil {
+			return nil, err
+		}
+		style = append(
+			style, color.Downgrade(depth).parameters(background)...)
+		background = false
+	}
+	if background {
+		return nil, fmt.Errorf("no color after \"on\" in %q", s)
+	}
+	return style, nil
+}
+
+// Theme is the styles of the elements of the screen.
+type Theme struct {
+	Query    Style
+	Selected Style
+	Marked   Style
+	// Matches of the query in the preview pane.
+	Match Style
+	// Matching lines of notes shown in results.
+	Snippet Style
+	Status  Style
+}
+
+var DEFAULT_THEME = Theme{
+	Selected: Style{7},
+	Marked:   Style{1},
+	Match:    Style{7},
+}
+
+// NewTheme returns the default theme overridden by the given styles, from the
+// names of elements such as "selected" to styles as parsed by ParseStyle.
+// Unknown elements and invalid styles are reported together.
+func NewTheme(styles map[string]string, depth int) (Theme, error) {
+	theme := DEFAULT_THEME
+	elements := map[string]*Style{
+		"query":    &theme.Query,
+		"selected": &theme.Selected,
+		"marked":   &theme.Marked,
+		"match":    &theme.Match,
+		"snippet":  &theme.Snippet,
+		"status":   &theme.Status,
+	}
+	// Sort the styles so that problems are reported in a stable order.
+	names := make([]string, 0, len(styles))
+	for name := range styles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	problems := []string{}
+	for _, name := range names {
+		element, ok := elements[name]
+		if !ok {
+			problems = append(
+				problems, fmt.Sprintf("unknown element %q", name))
+			continue
+		}
+		style, err := ParseStyle(styles[name], depth)
+		if err != nil {
+			problems = append(
+				problems, fmt.Sprintf("%s for %q", err, name))
+			continue
+		}
+		*element = style
+	}
+	if len(problems) > 0 {
+		return Theme{}, fmt.Errorf(
+			"invalid theme: %s", strings.Join(problems, "; "))
+	}
+	return theme, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		s     string
+		color Color
+	}{
+		{"red", Color{COLOR_16, 1, PALETTE[1]}},
+		{"Bright-Blue", Color{COLOR_16, 12, PALETTE[12]}},
+		{"grey", Color{COLOR_16, 8, PALETTE[8]}},
+		{"7", Color{COLOR_256, 7, PALETTE[7]}},
+		{"16", Color{COLOR_256, 16, [3]int{0x00, 0x00, 0x00}}},
+		{"208", Color{COLOR_256, 208, [3]int{0xFF, 0x87, 0x00}}},
+		{"231", Color{COLOR_256, 231, [3]int{0xFF, 0xFF, 0xFF}}},
+		{"232", Color{COLOR_256, 232, [3]int{0x08, 0x08, 0x08}}},
+		{"255", Color{COLOR_256, 255, [3]int{0xEE, 0xEE, 0xEE}}},
+		{"#FF8700", Color{COLOR_TRUECOLOR, 0, [3]int{0xFF, 0x87, 0x00}}},
+	}
+	for _, test := range tests {
+		color, err := ParseColor(test.s)
+		if err != nil || color != test.color {
+			t.Errorf("%q: got %v, %v, want %v", test.s, color, err, test.color)
+		}
+	}
+	for _, s := range []string{"", "purple", "bright-", "256", "-1", "#12345",
+		"#1234567", "#gggggg"} {
+		if color, err := ParseColor(s); err == nil {
+			t.Errorf("%q: got %v, want error", s, color)
+		}
+	}
+}
+
+func TestNearest(t *testing.T) {
+	tests := []struct {
+		name        string
+		rgb         [3]int
+		first, last int
+		n           int
+	}{
+		{"cube", [3]int{0xFF, 0x87, 0x00}, 16, 256, 208},
+		{"near cube", [3]int{0xFA, 0x80, 0x05}, 16, 256, 208},
+		{"gray ramp", [3]int{0x80, 0x80, 0x80}, 16, 256, 244},
+		{"near gray ramp", [3]int{0x31, 0x2F, 0x30}, 16, 256, 236},
+		{"basic", [3]int{0xFF, 0x00, 0x00}, 0, 16, 9},
+		{"near basic", [3]int{0xFF, 0x87, 0x00}, 0, 16, 3},
+		{"first", [3]int{0x00, 0x00, 0x00}, 16, 256, 16},
+	}
+	for _, test := range tests {
+		if n := nearest(test.rgb, test.first, test.last); n != test.n {
+			t.Errorf("%s: got %d, want %d", test.name, n, test.n)
+		}
+	}
+}
+
+func TestDowngrade(t *testing.T) {
+	tests := []struct {
+		name  string
+		color Color
+		depth int
+		want  Color
+	}{
+		{"supported", Color{COLOR_256, 208, indexedRGB(208)}, COLOR_256,
+			Color{COLOR_256, 208, indexedRGB(208)}},
+		{"basic", Color{COLOR_16, 9, PALETTE[9]}, COLOR_256,
+			Color{COLOR_16, 9, PALETTE[9]}},
+		{"truecolor to 256", Color{COLOR_TRUECOLOR, 0, [3]int{0xFF, 0x87, 0x00}},
+			COLOR_256, Color{COLOR_256, 208, indexedRGB(208)}},
+		{"truecolor to 16", Color{COLOR_TRUECOLOR, 0, [3]int{0xFF, 0x87, 0x00}},
+			COLOR_16, Color{COLOR_16, 3, PALETTE[3]}},
+		{"256 to 16", Color{COLOR_256, 196, indexedRGB(196)}, COLOR_16,
+			Color{COLOR_16, 9, PALETTE[9]}},
+		{"256 below 16", Color{COLOR_256, 3, PALETTE[3]}, COLOR_16,
+			Color{COLOR_16, 3, PALETTE[3]}},
+		{"bright 256 below 16", Color{COLOR_256, 12, PALETTE[12]}, COLOR_16,
+			Color{COLOR_16, 12, PALETTE[12]}},
+		{"none", Color{COLOR_16, 1, PALETTE[1]}, COLOR_NONE, Color{}},
+	}
+	for _, test := range tests {
+		if color := test.color.Downgrade(test.depth); color != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, color, test.want)
+		}
+	}
+}
+
+func TestParseStyle(t *testing.T) {
+	tests := []struct {
+		s     string
+		depth int
+		style Style
+	}{
+		{"none", COLOR_256, Style{}},
+		{"", COLOR_256, Style{}},
+		{"bold underline", COLOR_256, Style{1, 4}},
+		{"red", COLOR_16, Style{31}},
+		{"bright-red on blue", COLOR_16, Style{91, 44}},
+		{"on bright-black", COLOR_16, Style{100}},
+		{"bold yellow on #303030", COLOR_TRUECOLOR,
+			Style{1, 33, 48, 2, 0x30, 0x30, 0x30}},
+		{"bold yellow on #303030", COLOR_256, Style{1, 33, 48, 5, 236}},
+		{"208 on 3", COLOR_16, Style{33, 43}},
+		{"BOLD Red", COLOR_16, Style{1, 31}},
+		// Without colors, as with NO_COLOR, only the attributes are kept.
+		{"bold red on blue", COLOR_NONE, Style{1}},
+		{"#ff8700 on 208", COLOR_NONE, Style{}},
+	}
+	for _, test := range tests {
+		style, err := ParseStyle(test.s, test.depth)
+		if err != nil || !reflect.DeepEqual(style, test.style) {
+			t.Errorf("%q at depth %d: got %v, %v, want %v",
+				test.s, test.depth, style, err, test.style)
+		}
+	}
+	for _, s := range []string{"on", "red on", "bold on", "on bold",
+		"blink"} {
+		if style, err := ParseStyle(s, COLOR_256); err == nil {
+			t.Errorf("%q: got %v, want error", s, style)
+		}
+	}
+}
+
+func TestNewTheme(t *testing.T) {
+	theme, err := NewTheme(map[string]string{
+		"selected": "bold on 236",
+		"status":   "dim",
+		"marked":   "none",
+	}, COLOR_256)
+	want := DEFAULT_THEME
+	want.Selected = Style{1, 48, 5, 236}
+	want.Status = Style{2}
+	want.Marked = Style{}
+	if err != nil || !reflect.DeepEqual(theme, want) {
+		t.Errorf("got %v, %v, want %v", theme, err, want)
+	}
+
+	theme, err = NewTheme(nil, COLOR_NONE)
+	if err != nil || !reflect.DeepEqual(theme, DEFAULT_THEME) {
+		t.Errorf("got %v, %v, want the default theme", theme, err)
+	}
+
+	_, err = NewTheme(map[string]string{
+		"selected": "on",
+		"results":  "bold",
+		"match":    "purple",
+	}, COLOR_256)
+	problems := `invalid theme: unknown color "purple" for "match"; ` +
+		`unknown element "results"; no color after "on" in "on" for "selected"`
+	if err == nil || err.Error() != problems {
+		t.Errorf("got %v, want %q", err, problems)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path"
 )
@@ -11,6 +12,41 @@ type Config struct {
 	NotesDirectory string
 	// The file to write logs to, if omitted no logs will be written.
 	LogFile string
+	// Whether every matching line of a note is shown as a separate result.
+	ExpandLines bool
+	// The editor argument opening a note at a line, formatted with the line
+	// number. Defaults to "+%d" for editors known to support it, if omitted
+	// for other editors notes are opened at the top.
+	EditorLineArgument string
+	// The editor argument preceding several notes opened at once. Defaults
+	// to "-p" for editors known to open files in tabs with it, if omitted
+	// the notes are passed to other editors as they are.
+	EditorMultipleArgument string
+	// Key bindings overriding the defaults, from key chords such as "ctrl-j",
+	// "alt-b", "pgdn" or "\\e[1;5A" to action names. Escape sequences bind
+	// the key they encode, so "\\e[1;5A" binds "ctrl-up". Bind a key to
+	// "ignore" to unbind it.
+	KeyBindings map[string]string
+	// How the query is edited, either "emacs" or "vi". Defaults to "emacs".
+	EditingMode string
+	// Whether to leave the mouse to the terminal instead of using it to
+	// select results.
+	DisableMouse bool
+	// Whether to take the whole screen, on the alternate screen buffer, rather
+	// than the lines below the prompt.
+	FullScreen bool
+	// Where the query is drawn in full screen mode, either "top" or "bottom".
+	// Defaults to "top".
+	QueryPosition string
+	// Where the preview pane of the selected note is drawn, either "right" or
+	// "bottom". Defaults to "right".
+	PreviewPosition string
+	// Styles overriding the default theme, from the elements "query",
+	// "selected", "marked", "match", "snippet" and "status" to attributes
+	// and colors such as "bold yellow on #303030". Colors are named, indexed
+	// from 0 to 255 or given in hex, and are downgraded to what the terminal
+	// supports, or left out if NO_COLOR is set.
+	Theme map[string]string
 }
 
 func loadConfigFromFile(homeDir string, config *Config) error {
@@ -37,5 +73,28 @@ func LoadConfig() (*Config, error) {
 		}
 		config.NotesDirectory = path.Join(homeDir, "Notes")
 	}
+	switch config.EditingMode {
+	case "":
+		config.EditingMode = "emacs"
+	case "emacs", "vi":
+	default:
+		return nil, fmt.Errorf("unknown EditingMode %q", config.EditingMode)
+	}
+	switch config.QueryPosition {
+	case "":
+		config.QueryPosition = "top"
+	case "top", "bottom":
+	default:
+		return nil, fmt.Errorf(
+			"unknown QueryPosition %q", config.QueryPosition)
+	}
+	switch config.PreviewPosition {
+	case "":
+		config.PreviewPosition = "right"
+	case "right", "bottom":
+	default:
+		return nil, fmt.Errorf(
+			"unknown PreviewPosition %q", config.PreviewPosition)
+	}
 	return config, nil
 }
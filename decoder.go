@@ -0,0 +1,583 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	ESC = 0x1b
+	CSI = 0x5b
+	SS3 = 0x4f
+	DEL = 0x7f
+)
+
+// How long to wait after Escape for the rest of an escape sequence, before
+// taking it as a key press on its own.
+const ESCAPE_TIMEOUT = 50 * time.Millisecond
+
+// Names of keys sending CSI [1 ; <modifiers>] <final byte>.
+var CSI_KEYS = map[byte]string{
+	'A': "up",
+	'B': "down",
+	'C': "right",
+	'D': "left",
+	'H': "home",
+	'F': "end",
+	'P': "f1",
+	'Q': "f2",
+	'S': "f4",
+}
+
+// Names of keys sending SS3 <final byte> in application mode.
+var SS3_KEYS = map[byte]string{
+	'A': "up",
+	'B': "down",
+	'C': "right",
+	'D': "left",
+	'H': "home",
+	'F': "end",
+	'P': "f1",
+	'Q': "f2",
+	'R': "f3",
+	'S': "f4",
+	'M': "enter",
+}
+
+// Names of keys sending CSI <n> [; <modifiers>] ~.
+var TILDE_KEYS = map[string]string{
+	"1":  "home",
+	"2":  "insert",
+	"3":  "delete",
+	"4":  "end",
+	"5":  "pgup",
+	"6":  "pgdn",
+	"7":  "home",
+	"8":  "end",
+	"11": "f1",
+	"12": "f2",
+	"13": "f3",
+	"14": "f4",
+	"15": "f5",
+	"17": "f6",
+	"18": "f7",
+	"19": "f8",
+	"20": "f9",
+	"21": "f10",
+	"23": "f11",
+	"24": "f12",
+}
+
+// Names of keys with special codes in CSI u sequences.
+var CSI_U_KEYS = map[int]string{
+	9:     "tab",
+	13:    "enter",
+	27:    "esc",
+	127:   "backspace",
+	57414: "enter",
+}
+
+// Characters of keypad keys in CSI u sequences.
+var CSI_U_KEYPAD = map[int]rune{
+	57399: '0',
+	57400: '1',
+	57401: '2',
+	57402: '3',
+	57403: '4',
+	57404: '5',
+	57405: '6',
+	57406: '7',
+	57407: '8',
+	57408: '9',
+	57409: '.',
+	57410: '/',
+	57411: '*',
+	57412: '-',
+	57413: '+',
+	57415: '=',
+}
+
+// Bits of modifiers and motion in SGR mouse sequences.
+const (
+	MOUSE_SHIFT  = 4
+	MOUSE_ALT    = 8
+	MOUSE_CTRL   = 16
+	MOUSE_MOTION = 32
+)
+
+// Event is something that happened on the terminal.
+type Event interface {
+	isEvent()
+}
+
+// RuneEvent is a character typed without modifiers.
+type RuneEvent struct {
+	Rune rune
+}
+
+// KeyEvent is a special key, or a key pressed with modifiers. Escape
+// sequences that are not known keys are named by their bytes, with the
+// escape written as "\e".
+type KeyEvent struct {
+	Name      string
+	Modifiers Modifiers
+}
+
+// String returns the canonical name of the key, as used in key maps.
+func (e KeyEvent) String() string {
+	return keyName(e.Modifiers, e.Name)
+}
+
+// PasteEvent is text pasted in bracketed paste mode.
+type PasteEvent struct {
+	Text string
+}
+
+// MouseEvent is a mouse button pressed or released at a position of the
+// screen, starting at 1.
+type MouseEvent struct {
+	Button    int
+	Modifiers Modifiers
+	Motion    bool
+	Press     bool
+	X, Y      int
+}
+
+// CursorPositionEvent is the answer to a request for the cursor position.
+type CursorPositionEvent struct {
+	Row, Column int
+}
+
+// ResizeEvent is a change of the dimensions of the terminal, reported in
+// band.
+type ResizeEvent struct {
+	Height, Width int
+}
+
+// KeyboardFlagsEvent is the answer to a query of the flags of the keyboard
+// protocol, which only terminals supporting it send.
+type KeyboardFlagsEvent struct {
+	Flags int
+}
+
+// DeviceAttributesEvent is the answer to a request for the primary device
+// attributes.
+type DeviceAttributesEvent struct{}
+
+func (RuneEvent) isEvent()             {}
+func (KeyEvent) isEvent()              {}
+func (PasteEvent) isEvent()            {}
+func (MouseEvent) isEvent()            {}
+func (CursorPositionEvent) isEvent()   {}
+func (ResizeEvent) isEvent()           {}
+func (KeyboardFlagsEvent) isEvent()    {}
+func (DeviceAttributesEvent) isEvent() {}
+
+type chunk struct {
+	bytes []byte
+	err   error
+}
+
+// Decoder turns the bytes read from a terminal into events.
+type Decoder struct {
+	// How long to wait after Escape for the rest of an escape sequence.
+	EscapeTimeout time.Duration
+	chunks        chan chunk
+	buf           []byte
+	// The error reading more bytes, returned once buf is empty.
+	err error
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	d := &Decoder{ESCAPE_TIMEOUT, make(chan chunk), nil, nil}
+	go d.read(r)
+	return d
+}
+
+// read reads from r until it fails, so that waiting for bytes can time out.
+func (d *Decoder) read(r io.Reader) {
+	for {
+		buf := make([]byte, 4096)
+		n, err := r.Read(buf)
+		if n > 0 {
+			d.chunks <- chunk{buf[:n], nil}
+		}
+		if err != nil {
+			d.chunks <- chunk{nil, err}
+			return
+		}
+	}
+}
+
+func (d *Decoder) receive(c chunk) {
+	d.buf = append(d.buf, c.bytes...)
+	if c.err != nil {
+		d.err = c.err
+	}
+}
+
+func (d *Decoder) readByte() (byte, error) {
+	for len(d.buf) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		d.receive(<-d.chunks)
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b, nil
+}
+
+// readByteTimeout returns the next byte, or false if none arrives within the
+// escape timeout or reading fails.
+func (d *Decoder) readByteTimeout() (byte, bool) {
+	if len(d.buf) == 0 && d.err == nil {
+		select {
+		case c := <-d.chunks:
+			d.receive(c)
+		case <-time.After(d.EscapeTimeout):
+		}
+	}
+	if len(d.buf) == 0 {
+		return 0, false
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b, true
+}
+
+func (d *Decoder) unreadByte(b byte) {
+	d.buf = append([]byte{b}, d.buf...)
+}
+
+// readRune reads the rest of a UTF-8 encoded character starting with b.
+func (d *Decoder) readRune(b byte) (rune, error) {
+	buf := []byte{b}
+	for !utf8.FullRune(buf) {
+		b, err := d.readByte()
+		if err != nil {
+			return 0, err
+		}
+		buf = append(buf, b)
+	}
+	c, _ := utf8.DecodeRune(buf)
+	return c, nil
+}
+
+// controlKey returns the key producing a C0 control character.
+func controlKey(b byte) KeyEvent {
+	switch b {
+	case '\r':
+		return KeyEvent{"enter", 0}
+	case '\t':
+		return KeyEvent{"tab", 0}
+	case ESC:
+		return KeyEvent{"esc", 0}
+	case DEL:
+		return KeyEvent{"backspace", 0}
+	case 0x00:
+		return KeyEvent{"space", MOD_CTRL}
+	}
+	return KeyEvent{strings.ToLower(string(rune(b + 0x40))), MOD_CTRL}
+}
+
+// runeKey returns the key producing a character with modifiers.
+func runeKey(c rune, modifiers Modifiers) KeyEvent {
+	if c < 0x20 || c == DEL {
+		key := controlKey(byte(c))
+		key.Modifiers |= modifiers
+		return key
+	}
+	if c == ' ' {
+		return KeyEvent{"space", modifiers}
+	}
+	return KeyEvent{string(c), modifiers}
+}
+
+// Next returns the next event.
+func (d *Decoder) Next() (Event, error) {
+	for {
+		b, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == ESC {
+			event, err := d.decodeEscape()
+			if err != nil {
+				return nil, err
+			}
+			if event == nil {
+				continue
+			}
+			return event, nil
+		}
+		if b < 0x20 || b == DEL {
+			return controlKey(b), nil
+		}
+		c, err := d.readRune(b)
+		if err != nil {
+			return nil, err
+		}
+		return RuneEvent{c}, nil
+	}
+}
+
+// decodeEscape decodes what follows an escape, or returns nil for sequences
+// that are ignored.
+func (d *Decoder) decodeEscape() (Event, error) {
+	// Read the second byte, if it follows quickly enough to be part of a
+	// sequence rather than a separate key.
+	b, ok := d.readByteTimeout()
+	if !ok {
+		return KeyEvent{"esc", 0}, nil
+	}
+	switch {
+	case b == CSI:
+		return d.decodeCSI(0)
+	case b == SS3:
+		return d.decodeSS3(0), nil
+	case b == ESC:
+		// An Alt modified key sending an escape sequence itself.
+		next, ok := d.readByteTimeout()
+		if ok && next == CSI {
+			return d.decodeCSI(MOD_ALT)
+		}
+		if ok && next == SS3 {
+			return d.decodeSS3(MOD_ALT), nil
+		}
+		if ok {
+			d.unreadByte(next)
+		}
+		return KeyEvent{"esc", MOD_ALT}, nil
+	default:
+		c, err := d.readRune(b)
+		if err != nil {
+			return nil, err
+		}
+		return runeKey(c, MOD_ALT), nil
+	}
+}
+
+// decodeSS3 decodes the rest of an SS3 sequence, sent by cursor and function
+// keys in application mode. Some terminals put the modifiers before the final
+// byte, as in SS3 5 A for Ctrl-Up.
+func (d *Decoder) decodeSS3(modifiers Modifiers) Event {
+	b, ok := d.readByteTimeout()
+	if !ok {
+		// Not a sequence, but Alt-O.
+		return KeyEvent{"O", modifiers | MOD_ALT}
+	}
+	parameter := []byte{}
+	for ok && b >= '0' && b <= '9' {
+		parameter = append(parameter, b)
+		b, ok = d.readByteTimeout()
+	}
+	name, known := SS3_KEYS[b]
+	if !ok || !known {
+		if ok {
+			parameter = append(parameter, b)
+		}
+		return KeyEvent{fmt.Sprintf("\\eO%s", parameter), 0}
+	}
+	modifiers |= csiModifiers([]string{"1", string(parameter)})
+	return KeyEvent{name, modifiers}
+}
+
+// csiModifiers returns the modifiers encoded in the second parameter of a
+// CSI sequence, as in CSI 1 ; 5 A for Ctrl-Up.
+func csiModifiers(parameters []string) Modifiers {
+	if len(parameters) < 2 {
+		return 0
+	}
+	// Modifiers may be followed by sub-parameters, as in CSI u sequences.
+	n, err := strconv.Atoi(strings.Split(parameters[1], ":")[0])
+	if err != nil || n < 1 {
+		return 0
+	}
+	n--
+	var modifiers Modifiers
+	if n&1 != 0 {
+		modifiers |= MOD_SHIFT
+	}
+	if n&(2|8) != 0 {
+		modifiers |= MOD_ALT
+	}
+	if n&4 != 0 {
+		modifiers |= MOD_CTRL
+	}
+	return modifiers
+}
+
+// integers parses the integer parameters of a sequence.
+func integers(parameters []string) ([]int, error) {
+	values := make([]int, len(parameters))
+	for i, parameter := range parameters {
+		value, err := strconv.Atoi(parameter)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func (d *Decoder) decodeCSI(modifiers Modifiers) (Event, error) {
+	b, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	// Read the parameter bytes.
+	parameterBytes := []byte{}
+	for {
+		if !(b >= 0x30 && b <= 0x3f) {
+			break
+		}
+		parameterBytes = append(parameterBytes, b)
+		b, err = d.readByte()
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Read the intermediate bytes.
+	intermediateBytes := []byte{}
+	for {
+		if !(b >= 0x20 && b <= 0x2f) {
+			break
+		}
+		intermediateBytes = append(intermediateBytes, b)
+		b, err = d.readByte()
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Read the final byte.
+	if !(b >= 0x40 && b <= 0x7e) {
+		Logger.Printf("Ignoring invalid escape sequence ending with %q", b)
+		if b == ESC {
+			// The start of the next sequence.
+			d.unreadByte(b)
+		}
+		return nil, nil
+	}
+	private := len(parameterBytes) > 0 && parameterBytes[0] == '?'
+	sgrMouse := len(parameterBytes) > 0 && parameterBytes[0] == '<'
+	parameters := strings.Split(
+		strings.TrimLeft(string(parameterBytes), "?<"), ";")
+	modifiers |= csiModifiers(parameters)
+	switch b {
+	case '~':
+		if parameters[0] == "200" {
+			return d.decodePaste()
+		}
+		if name, ok := TILDE_KEYS[parameters[0]]; ok {
+			return KeyEvent{name, modifiers}, nil
+		}
+	case 'R':
+		values, err := integers(parameters)
+		if err != nil || len(values) != 2 {
+			break
+		}
+		return CursorPositionEvent{values[0], values[1]}, nil
+	case 'M', 'm':
+		if !sgrMouse {
+			break
+		}
+		values, err := integers(parameters)
+		if err != nil || len(values) != 3 {
+			break
+		}
+		var mouseModifiers Modifiers
+		if values[0]&MOUSE_SHIFT != 0 {
+			mouseModifiers |= MOD_SHIFT
+		}
+		if values[0]&MOUSE_ALT != 0 {
+			mouseModifiers |= MOD_ALT
+		}
+		if values[0]&MOUSE_CTRL != 0 {
+			mouseModifiers |= MOD_CTRL
+		}
+		return MouseEvent{
+			values[0] &^ (MOUSE_SHIFT | MOUSE_ALT | MOUSE_CTRL | MOUSE_MOTION),
+			mouseModifiers,
+			values[0]&MOUSE_MOTION != 0,
+			b == 'M',
+			values[1], values[2]}, nil
+	case 't':
+		values, err := integers(parameters)
+		if err != nil || len(values) < 3 || values[0] != 48 {
+			break
+		}
+		return ResizeEvent{values[1], values[2]}, nil
+	case 'u':
+		if private {
+			flags, err := strconv.Atoi(parameters[0])
+			if err != nil {
+				break
+			}
+			return KeyboardFlagsEvent{flags}, nil
+		}
+		return decodeCSIu(parameters, modifiers), nil
+	case 'c':
+		if private {
+			return DeviceAttributesEvent{}, nil
+		}
+	case 'Z':
+		return KeyEvent{"tab", modifiers | MOD_SHIFT}, nil
+	default:
+		if name, ok := CSI_KEYS[b]; ok {
+			return KeyEvent{name, modifiers}, nil
+		}
+	}
+	// Unknown sequences can still be bound by their bytes.
+	return KeyEvent{
+		fmt.Sprintf("\\e[%s%s%c", parameterBytes, intermediateBytes, b),
+		0}, nil
+}
+
+// decodeCSIu decodes a key reported as CSI <code> ; <modifiers> u by the
+// keyboard protocol.
+func decodeCSIu(parameters []string, modifiers Modifiers) Event {
+	code, err := strconv.Atoi(strings.Split(parameters[0], ":")[0])
+	if err != nil || code < 0 || code > unicode.MaxRune {
+		return KeyEvent{
+			fmt.Sprintf("\\e[%su", strings.Join(parameters, ";")), 0}
+	}
+	if name, ok := CSI_U_KEYS[code]; ok {
+		return KeyEvent{name, modifiers}
+	}
+	c := rune(code)
+	if keypad, ok := CSI_U_KEYPAD[code]; ok {
+		c = keypad
+	}
+	if modifiers&MOD_SHIFT != 0 && unicode.IsLetter(c) {
+		c = unicode.ToUpper(c)
+		modifiers &^= MOD_SHIFT
+	}
+	if modifiers == 0 && c >= 0x20 && c != DEL {
+		return RuneEvent{c}
+	}
+	if modifiers&MOD_CTRL != 0 {
+		c = unicode.ToLower(c)
+	}
+	return runeKey(c, modifiers)
+}
+
+// decodePaste reads text pasted in bracketed paste mode, up to CSI 201~.
+func (d *Decoder) decodePaste() (Event, error) {
+	end := []byte{ESC, CSI, '2', '0', '1', '~'}
+	pasted := []byte{}
+	for !bytes.HasSuffix(pasted, end) {
+		b, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		pasted = append(pasted, b)
+	}
+	return PasteEvent{string(pasted[:len(pasted)-len(end)])}, nil
+}
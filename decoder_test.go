@@ -0,0 +1,205 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func init() {
+	Logger = log.New(ioutil.Discard, "", 0)
+}
+
+// decodeAll returns the events decoded from input, until reading fails.
+func decodeAll(input string) ([]Event, error) {
+	d := NewDecoder(strings.NewReader(input))
+	d.EscapeTimeout = 10 * time.Millisecond
+	events := []Event{}
+	for {
+		event, err := d.Next()
+		if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		events []Event
+	}{
+		{"runes", "aé€", []Event{RuneEvent{'a'}, RuneEvent{'é'}, RuneEvent{'€'}}},
+		{"enter", "\r", []Event{KeyEvent{"enter", 0}}},
+		{"tab", "\t", []Event{KeyEvent{"tab", 0}}},
+		{"backspace", "\x7f", []Event{KeyEvent{"backspace", 0}}},
+		{"ctrl", "\x01\x00", []Event{
+			KeyEvent{"a", MOD_CTRL}, KeyEvent{"space", MOD_CTRL}}},
+		{"esc", "\x1b", []Event{KeyEvent{"esc", 0}}},
+		{"alt", "\x1bb\x1b\x7f", []Event{
+			KeyEvent{"b", MOD_ALT}, KeyEvent{"backspace", MOD_ALT}}},
+		{"alt esc", "\x1b\x1b", []Event{KeyEvent{"esc", MOD_ALT}}},
+		{"cursor keys", "\x1b[A\x1b[B\x1b[C\x1b[D", []Event{
+			KeyEvent{"up", 0}, KeyEvent{"down", 0},
+			KeyEvent{"right", 0}, KeyEvent{"left", 0}}},
+		{"modified keys", "\x1b[1;5H\x1b[1;2D\x1b[1;3A", []Event{
+			KeyEvent{"home", MOD_CTRL}, KeyEvent{"left", MOD_SHIFT},
+			KeyEvent{"up", MOD_ALT}}},
+		{"alt sequence", "\x1b\x1b[A", []Event{KeyEvent{"up", MOD_ALT}}},
+		{"tilde keys", "\x1b[3~\x1b[5;5~\x1b[24~", []Event{
+			KeyEvent{"delete", 0}, KeyEvent{"pgup", MOD_CTRL},
+			KeyEvent{"f12", 0}}},
+		{"back tab", "\x1b[Z", []Event{KeyEvent{"tab", MOD_SHIFT}}},
+		{"ss3", "\x1bOA\x1bOP\x1bOM", []Event{
+			KeyEvent{"up", 0}, KeyEvent{"f1", 0}, KeyEvent{"enter", 0}}},
+		{"modified ss3", "\x1bO5A\x1bO2P", []Event{
+			KeyEvent{"up", MOD_CTRL}, KeyEvent{"f1", MOD_SHIFT}}},
+		{"alt O", "\x1bO", []Event{KeyEvent{"O", MOD_ALT}}},
+		{"paste", "\x1b[200~a\r\x1b[Ab\x1b[201~c", []Event{
+			PasteEvent{"a\r\x1b[Ab"}, RuneEvent{'c'}}},
+		{"mouse", "\x1b[<0;3;4M\x1b[<0;3;4m\x1b[<64;1;2M\x1b[<48;5;6M",
+			[]Event{
+				MouseEvent{0, 0, false, true, 3, 4},
+				MouseEvent{0, 0, false, false, 3, 4},
+				MouseEvent{64, 0, false, true, 1, 2},
+				MouseEvent{0, MOD_CTRL, true, true, 5, 6}}},
+		{"cursor position", "\x1b[12;80R", []Event{
+			CursorPositionEvent{12, 80}}},
+		{"resize", "\x1b[48;24;80;384;640t", []Event{ResizeEvent{24, 80}}},
+		{"keyboard flags", "\x1b[?1u", []Event{KeyboardFlagsEvent{1}}},
+		{"device attributes", "\x1b[?62;22c", []Event{
+			DeviceAttributesEvent{}}},
+		{"csi u", "\x1b[97;5u\x1b[27u\x1b[105;2u\x1b[57400u\x1b[99;7u",
+			[]Event{
+				KeyEvent{"a", MOD_CTRL}, KeyEvent{"esc", 0}, RuneEvent{'I'},
+				RuneEvent{'1'}, KeyEvent{"c", MOD_CTRL | MOD_ALT}}},
+		{"csi u enter", "\x1b[13u\x1b[9;2u", []Event{
+			KeyEvent{"enter", 0}, KeyEvent{"tab", MOD_SHIFT}}},
+		{"unknown", "\x1b[99X\x1bOz", []Event{
+			KeyEvent{"\\e[99X", 0}, KeyEvent{"\\eOz", 0}}},
+		{"invalid", "\x1b[1\x1b[A", []Event{KeyEvent{"up", 0}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events, err := decodeAll(test.input)
+			if err != io.EOF {
+				t.Fatalf("got error %v, want EOF", err)
+			}
+			if !reflect.DeepEqual(events, test.events) {
+				t.Errorf("got %#v, want %#v", events, test.events)
+			}
+		})
+	}
+}
+
+func TestDecoderEscapeTimeout(t *testing.T) {
+	r, w := io.Pipe()
+	d := NewDecoder(r)
+	d.EscapeTimeout = 10 * time.Millisecond
+	go func() {
+		w.Write([]byte{ESC})
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("[A"))
+		w.Close()
+	}()
+	want := []Event{KeyEvent{"esc", 0}, RuneEvent{'['}, RuneEvent{'A'}}
+	for _, expected := range want {
+		event, err := d.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(event, expected) {
+			t.Errorf("got %#v, want %#v", event, expected)
+		}
+	}
+}
+
+func TestKeyEventString(t *testing.T) {
+	tests := []struct {
+		event KeyEvent
+		name  string
+	}{
+		{KeyEvent{"a", MOD_CTRL}, "ctrl-a"},
+		{KeyEvent{"up", MOD_CTRL | MOD_ALT | MOD_SHIFT}, "ctrl-alt-shift-up"},
+		{KeyEvent{"esc", 0}, "esc"},
+	}
+	for _, test := range tests {
+		if name := test.event.String(); name != test.name {
+			t.Errorf("got %q, want %q", name, test.name)
+		}
+	}
+}
+
+func TestParseKeySequence(t *testing.T) {
+	tests := []struct {
+		chord string
+		name  string
+	}{
+		{"\\e[1;5A", "ctrl-up"},
+		{"\x1b[1;5H", "ctrl-home"},
+		{"\\e", "esc"},
+		{"\\ea", "alt-a"},
+		{"\\e[99X", "\\e[99X"},
+		{"\\e[1;5Aa", ""},
+		{"\\e[<0;1;1M", ""},
+	}
+	for _, test := range tests {
+		name, err := ParseKey(test.chord)
+		if test.name == "" {
+			if err == nil {
+				t.Errorf("%q: got %q, want error", test.chord, name)
+			}
+			continue
+		}
+		if err != nil || name != test.name {
+			t.Errorf("%q: got %q, %v, want %q", test.chord, name, err, test.name)
+		}
+	}
+}
+
+// The default key bindings must use the names the decoder gives keys, or they
+// never fire.
+func TestDefaultKeyBindingsCanonical(t *testing.T) {
+	for key := range DEFAULT_KEY_BINDINGS {
+		if name, err := ParseKey(key); err != nil || name != key {
+			t.Errorf("%q: got %q, %v, want %q", key, name, err, key)
+		}
+	}
+	events, _ := decodeAll("\x1b[1;5H\x1b[1;5F")
+	if len(events) != 2 {
+		t.Fatalf("got %#v, want two keys", events)
+	}
+	for i, name := range []string{"ctrl-home", "ctrl-end"} {
+		key, ok := events[i].(KeyEvent)
+		if !ok || key.String() != name {
+			t.Errorf("got %#v, want %q", events[i], name)
+		}
+		if _, ok := DEFAULT_KEY_BINDINGS[name]; !ok {
+			t.Errorf("%q is not bound", name)
+		}
+	}
+}
+
+func FuzzDecoder(f *testing.F) {
+	f.Add("a\r\x1b")
+	f.Add("\x1b[1;5A\x1bOP\x1b[3~")
+	f.Add("\x1b[200~text\x1b[201~")
+	f.Add("\x1b[<0;3;4M\x1b[12;80R\x1b[48;24;80t")
+	f.Add("\x1b[?1u\x1b[?62c\x1b[97;5u\x1b[1114112u")
+	f.Fuzz(func(t *testing.T, input string) {
+		events, err := decodeAll(input)
+		if err != io.EOF {
+			t.Fatalf("got error %v, want EOF", err)
+		}
+		for _, event := range events {
+			if event == nil {
+				t.Fatal("got nil event")
+			}
+		}
+	})
+}
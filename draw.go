@@ -4,39 +4,141 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
+// SPINNER_FRAMES are drawn in turn in the status line while searching, each
+// for SPINNER_INTERVAL.
+var SPINNER_FRAMES = []string{
+	"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+const SPINNER_INTERVAL = 100 * time.Millisecond
+
 type DrawManagerOptions struct {
 	Writer             io.Writer
 	Search             *SearchClient
 	TerminalDimensions *TerminalDimensionsClient
+	Input              *InputClient
+	Menu               *MenuClient
+	Preview            *PreviewClient
+	// Where the preview pane is drawn, PREVIEW_RIGHT or PREVIEW_BOTTOM.
+	PreviewPosition int
+	// Whether the whole screen is drawn on, rather than lines below the
+	// prompt.
+	FullScreen bool
+	// Whether the query is drawn at the bottom of the screen, with results
+	// above it, in full screen mode.
+	QueryAtBottom bool
+	// The height of the region drawn in below the prompt, if limited.
+	Height Height
+	Theme  Theme
+}
+
+// Height is a number of rows, or a percentage of the rows of the terminal.
+// The zero Height is unlimited.
+type Height struct {
+	N       int
+	Percent bool
+}
+
+// ParseHeight parses a height such as "10" or "40%".
+func ParseHeight(s string) (Height, error) {
+	h := Height{}
+	digits := strings.TrimSuffix(s, "%")
+	h.Percent = digits != s
+	n, err := strconv.Atoi(digits)
+	if err != nil || n < 1 || (h.Percent && n > 100) {
+		return h, fmt.Errorf("invalid height %q", s)
+	}
+	h.N = n
+	return h, nil
+}
+
+// Rows returns the number of rows of a terminal of the given height taken by
+// the region, including the query. The region takes at least the query and
+// the status line, however small the percentage.
+func (h Height) Rows(height int) int {
+	rows := h.N
+	if h.Percent {
+		rows = h.N * height / 100
+	}
+	if rows < 2 {
+		rows = 2
+	}
+	if rows > height {
+		rows = height
+	}
+	return rows
 }
 
 type DrawManager struct {
 	Options  DrawManagerOptions
 	w        *bufio.Writer
 	maxLines int
+	// The result drawn on each row, relative to the query, which is 0 and
+	// mapped to -1.
+	rendered map[int]int
+	// The index of the first result drawn, scrolled to keep the selection in
+	// view.
+	offset int
+	// The number of rows of results and of the preview pane drawn.
+	resultRows  int
+	previewRows int
+	// Held while drawing, so that cleaning up does not interleave with it.
+	drawing *sync.Mutex
+	// Whether the screen was cleaned up, after which nothing is drawn until
+	// resuming.
+	stopped bool
+	// Whether the spinner is turning, while searching.
+	spinning bool
+	// Notified to draw again after resuming.
+	redrawTrigger *Trigger
+	mutex         *sync.RWMutex
 }
 
 func NewDrawManager(options DrawManagerOptions) *DrawManager {
-	return &DrawManager{options, nil, 1}
+	return &DrawManager{
+		options,
+		nil,
+		1,
+		nil,
+		0, 0, 0,
+		&sync.Mutex{},
+		false,
+		false,
+		NewTrigger(),
+		&sync.RWMutex{}}
+}
+
+func (dm *DrawManager) Client() *DrawClient {
+	return &DrawClient{dm}
 }
 
-// TODO: this needs to be more robust
+// Cleanup stops drawing and erases what was drawn, from the start of the
+// query line down, leaving the cursor where the query was.
 func (dm *DrawManager) Cleanup() error {
+	dm.drawing.Lock()
+	defer dm.drawing.Unlock()
+	dm.stopped = true
 	var err error
-	ansi := ANSI{os.Stdout}
-	err = ansi.ED(0)
-	if err != nil {
-		return err
-	}
+	ansi := ANSI{dm.Options.Writer}
 	err = ansi.CR()
 	if err != nil {
 		return err
 	}
-	return nil
+	return ansi.ED(0)
+}
+
+// Resume draws again after Cleanup, starting from the line of the cursor.
+func (dm *DrawManager) Resume() {
+	dm.drawing.Lock()
+	dm.stopped = false
+	dm.maxLines = 1
+	dm.drawing.Unlock()
+	dm.redrawTrigger.Notify()
 }
 
 func (dm *DrawManager) Start() error {
@@ -49,10 +151,23 @@ func (dm *DrawManager) Start() error {
 	if dm.Options.TerminalDimensions == nil {
 		return fmt.Errorf("no TerminalDimensions")
 	}
+	if dm.Options.Input == nil {
+		return fmt.Errorf("no Input")
+	}
+	if dm.Options.Menu == nil {
+		return fmt.Errorf("no Menu")
+	}
+	if dm.Options.Preview == nil {
+		return fmt.Errorf("no Preview")
+	}
 	dm.w = bufio.NewWriter(dm.Options.Writer)
 	subscription := NewAnySubscription(
 		dm.Options.Search.Subscribe(),
-		dm.Options.TerminalDimensions.Subscribe())
+		dm.Options.TerminalDimensions.Subscribe(),
+		dm.Options.Input.Subscribe(),
+		dm.Options.Menu.Subscribe(),
+		dm.Options.Preview.Subscribe(),
+		dm.redrawTrigger.Subscribe())
 	var err error
 	Logger.Print("Starting DrawManager")
 	for {
@@ -60,24 +175,65 @@ func (dm *DrawManager) Start() error {
 		if err != nil {
 			return err
 		}
+		dm.mutex.Lock()
+		if !dm.spinning && dm.Options.Search.Status().Searching {
+			dm.spinning = true
+			go dm.spin()
+		}
+		dm.mutex.Unlock()
 		subscription.Wait()
 	}
 }
 
-// TODO: handle wide unicaode characters
-func printLine(ansi ANSI, line string, width int, selected bool) error {
-	var err error
+// span is a piece of a line printed in a style.
+type span struct {
+	text  string
+	style Style
+}
 
-	// Inverse, if selected
-	if selected {
-		err = ansi.SGR(7)
-		if err != nil {
-			return err
+// truncateSpans shortens spans to fit in a number of cells, ending the last
+// one kept with an ellipsis if they do not fit.
+func truncateSpans(spans []span, width int) []span {
+	var b strings.Builder
+	for _, s := range spans {
+		b.WriteString(s.text)
+	}
+	full := b.String()
+	text := truncate(full, width)
+	if text == full {
+		return spans
+	}
+	if text == "" {
+		return nil
+	}
+	// The length of the text kept before the ellipsis
+	cut := len(text) - len("…")
+	truncated := []span{}
+	start := 0
+	for _, s := range spans {
+		if start+len(s.text) >= cut {
+			truncated = append(
+				truncated, span{s.text[:cut-start] + "…", s.style})
+			break
 		}
+		truncated = append(truncated, s)
+		start += len(s.text)
 	}
+	return truncated
+}
+
+// printLine erases the line and prints the spans, potentially truncated, in
+// their styles on top of the style of the line, which fills it.
+func printLine(ansi ANSI, spans []span, width int, style Style) error {
+	var err error
+	styled := len(style) > 0
 
 	// Erase line
-	if selected {
+	if styled {
+		err = ansi.SGR(style...)
+		if err != nil {
+			return err
+		}
 		err = ansi.CR()
 		if err != nil {
 			return err
@@ -99,22 +255,32 @@ func printLine(ansi ANSI, line string, width int, selected bool) error {
 		return err
 	}
 
-	// Print, potentially with truncation
-	if len(line) > width {
-		format := fmt.Sprintf("%%.%ds…", width-1)
-		_, err = fmt.Fprintf(ansi, format, line)
-		if err != nil {
-			return err
+	return printSpans(ansi, spans, width, style)
+}
+
+// printSpans prints spans, potentially truncated, in their styles on top of
+// the style of the line.
+func printSpans(ansi ANSI, spans []span, width int, style Style) error {
+	var err error
+	styled := len(style) > 0
+	for _, s := range truncateSpans(spans, width) {
+		parameters := append(append(Style{}, style...), s.style...)
+		// Reset the style of the previous span, and set this one's.
+		if styled || len(s.style) > 0 {
+			err = ansi.SGR(append(Style{0}, parameters...)...)
+			if err != nil {
+				return err
+			}
+			styled = len(parameters) > 0
 		}
-	} else {
-		_, err = fmt.Fprint(ansi, line)
+		_, err = fmt.Fprint(ansi, s.text)
 		if err != nil {
 			return err
 		}
 	}
 
-	// Disable inverse, if selected
-	if selected {
+	// Reset style, if styled
+	if styled {
 		err = ansi.SGR(0)
 		if err != nil {
 			return err
@@ -123,11 +289,230 @@ func printLine(ansi ANSI, line string, width int, selected bool) error {
 	return nil
 }
 
+// previewSpans splits a line of the preview pane into spans, with the
+// matches of the query in a style.
+func previewSpans(pl PreviewLine, match Style) []span {
+	spans := []span{}
+	start := 0
+	for _, m := range pl.Matches {
+		spans = append(spans,
+			span{pl.Text[start:m[0]], nil},
+			span{pl.Text[m[0]:m[1]], match})
+		start = m[1]
+	}
+	return append(spans, span{pl.Text[start:], nil})
+}
+
+// drawLine draws a line, followed by its line of the preview pane when the
+// pane is on the right.
+func drawLine(ansi ANSI, f frame, l line, width int) error {
+	var err error
+	if l.preview == nil {
+		return printLine(ansi, l.spans, width, l.style)
+	}
+	preview := previewSpans(*l.preview, f.match)
+	if f.previewColumn == 0 {
+		return printLine(ansi, preview, width, nil)
+	}
+	// Leave a space between the text and the pane
+	err = printLine(ansi, l.spans, f.previewColumn-1, l.style)
+	if err != nil {
+		return err
+	}
+
+	// Erase the rest of the line, which the text may not have
+	err = ansi.CR()
+	if err != nil {
+		return err
+	}
+	err = ansi.CUF(f.previewColumn)
+	if err != nil {
+		return err
+	}
+	err = ansi.EL(0)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprint(ansi, "│")
+	if err != nil {
+		return err
+	}
+	return printSpans(ansi, preview, width-f.previewColumn-1, nil)
+}
+
+// scroll returns the index of the first of count items drawn on the given
+// number of rows, starting from offset and moved as little as possible to
+// show the selected item.
+func scroll(offset, selection, count, rows int) int {
+	if selection >= 0 && selection < offset {
+		offset = selection
+	}
+	if selection >= offset+rows {
+		offset = selection - rows + 1
+	}
+	if offset > count-rows {
+		offset = count - rows
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return offset
+}
+
+// line is a result or menu item drawn next to the query.
+type line struct {
+	spans []span
+	// The style of the whole line, as when selected or marked.
+	style Style
+	// The index of the result or menu item, or -1 for a line of the preview
+	// pane or a blank line beside it.
+	index int
+	// The line of the preview pane drawn beside the text, or in place of it
+	// when the pane is at the bottom.
+	preview *PreviewLine
+}
+
+// frame is what is drawn on the screen.
+type frame struct {
+	query []span
+	// The style of the whole query line, as when selected.
+	queryStyle Style
+	// The position of the cursor in the query, in cells.
+	cursor int
+	lines  []line
+	// The column the preview pane starts at when it is on the right, or 0.
+	previewColumn int
+	// The style of matches of the query in the preview pane.
+	match Style
+}
+
 func (dm *DrawManager) draw() error {
+	dm.drawing.Lock()
+	defer dm.drawing.Unlock()
+	if dm.stopped {
+		return nil
+	}
 	Logger.Print("Drawing")
 
 	selection, results := dm.Options.Search.Results()
-	width, _ := dm.Options.TerminalDimensions.Dimensions()
+	width, height := dm.Options.TerminalDimensions.Dimensions()
+	// Only as many rows are drawn besides the query as fit on the screen, or
+	// in the region, so that drawing never scrolls the query off it.
+	rows := height - 1
+	if !dm.Options.FullScreen && dm.Options.Height.N > 0 {
+		rows = dm.Options.Height.Rows(height) - 1
+	}
+	if rows < 0 {
+		rows = 0
+	}
+	// The status line takes the first of them.
+	showStatus := rows > 0
+	if showStatus {
+		rows--
+	}
+
+	// The query, or the note whose related notes are shown
+	query, cursor := dm.Options.Search.QueryCursor()
+	if related := dm.Options.Search.Related(); related != "" {
+		query = fmt.Sprintf("Related to %s", related)
+		cursor = len([]rune(query))
+	}
+	marked := dm.Options.Search.Marked()
+	status := dm.status(selection, results, len(marked))
+	// The menu is drawn in place of the query and results.
+	menu := dm.Options.Menu.View()
+	if menu.Open {
+		query, cursor = menu.Header, menu.Cursor
+		selection = menu.Selection
+		results = nil
+	}
+	if n := len([]rune(query)); cursor > n {
+		cursor = n
+	}
+	theme := dm.Options.Theme
+	f := frame{
+		[]span{{query, theme.Query}},
+		nil,
+		// The cursor is counted in runes, which may take zero to two cells.
+		stringWidth(string([]rune(query)[:cursor])),
+		nil,
+		0,
+		theme.Match}
+	if selection == -1 {
+		f.queryStyle = theme.Selected
+	}
+	// lineStyle returns the style of a selected or marked line.
+	lineStyle := func(selected, marked bool) Style {
+		style := Style{}
+		if selected {
+			style = append(style, theme.Selected...)
+		}
+		if marked {
+			style = append(style, theme.Marked...)
+		}
+		return style
+	}
+
+	// The preview pane takes every row when on the right, or the rows below
+	// half of them and a separator when at the bottom.
+	preview := dm.Options.Preview.View()
+	showPreview := preview.Open && !menu.Open &&
+		!(dm.Options.PreviewPosition == PREVIEW_BOTTOM && rows < 3)
+	resultRows, previewRows := rows, 0
+	if showPreview {
+		previewRows = rows
+		if dm.Options.PreviewPosition == PREVIEW_BOTTOM {
+			resultRows = (rows - 1) / 2
+			previewRows = rows - 1 - resultRows
+		} else {
+			f.previewColumn = width / 2
+		}
+	}
+
+	// Menu items
+	offset := scroll(0, selection, len(menu.Items), resultRows)
+	for i := offset; i < len(menu.Items) && i < offset+resultRows; i++ {
+		f.lines = append(f.lines, line{
+			[]span{{menu.Items[i], nil}},
+			lineStyle(selection == i, false),
+			i,
+			nil})
+	}
+
+	// Results
+	dm.offset = scroll(dm.offset, selection, len(results), resultRows)
+	for i := dm.offset; i < len(results) && i < dm.offset+resultRows; i++ {
+		result := results[i]
+		spans := []span{{result.Label(), nil}}
+		if result.Snippet != "" {
+			spans = []span{
+				{strings.TrimSuffix(result.Label(), result.Snippet), nil},
+				{result.Snippet, theme.Snippet}}
+		}
+		f.lines = append(f.lines, line{
+			spans,
+			lineStyle(selection == i, marked[result.key()]),
+			i,
+			nil})
+	}
+
+	// Preview
+	if showPreview {
+		f.lines = dm.addPreview(
+			f.lines, preview.Lines, resultRows, previewRows, width)
+	}
+
+	// Status
+	if showStatus {
+		f.lines = append(
+			[]line{{[]span{{status, nil}}, theme.Status, -1, nil}},
+			f.lines...)
+	}
+	dm.mutex.Lock()
+	dm.resultRows = resultRows
+	dm.previewRows = previewRows
+	dm.mutex.Unlock()
+
 	ansi := ANSI{dm.w}
 	var err error
 
@@ -137,69 +522,312 @@ func (dm *DrawManager) draw() error {
 		return err
 	}
 
-	// Write query
-	query := dm.Options.Search.Query()
-	err = printLine(ansi, query, width, selection == -1)
+	scrolled := false
+	if dm.Options.FullScreen {
+		err = dm.drawFullScreen(ansi, f, width, height)
+	} else {
+		scrolled, err = dm.drawInline(ansi, f, width)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Show cursor
+	err = ansi.DECTCEM(true)
+	if err != nil {
+		return err
+	}
+
+	err = dm.w.Flush()
 	if err != nil {
 		return err
 	}
 
-	// Write results
-	for i, result := range results {
+	// Record the rows the lines were drawn on, relative to the query.
+	rendered := map[int]int{0: -1}
+	for i, l := range f.lines {
+		if l.index >= 0 {
+			rendered[dm.direction()*(i+1)] = l.index
+		}
+	}
+	dm.mutex.Lock()
+	dm.rendered = rendered
+	dm.mutex.Unlock()
+
+	if scrolled {
+		dm.Options.TerminalDimensions.RequestOrigin()
+	}
+	return nil
+}
+
+// status returns the status line: a spinner while searching, the position of
+// the selection among the results, how long the search took, the modes of
+// searching and editing, the number of marked results, and the last error.
+func (dm *DrawManager) status(
+	selection int, results []Result, marked int) string {
+	status := dm.Options.Search.Status()
+	parts := []string{}
+	if status.Searching {
+		frame := time.Now().UnixNano() / int64(SPINNER_INTERVAL)
+		parts = append(parts, SPINNER_FRAMES[frame%int64(len(SPINNER_FRAMES))])
+	}
+	parts = append(parts, fmt.Sprintf("%d/%d", selection+1, len(results)))
+	parts = append(parts, fmt.Sprintf("%dms", status.Duration/time.Millisecond))
+	parts = append(parts, status.Modes...)
+	if mode := dm.Options.Input.Mode(); mode != "" {
+		parts = append(parts, mode)
+	}
+	if marked > 0 {
+		parts = append(parts, fmt.Sprintf("%d marked", marked))
+	}
+	if status.Error != "" {
+		// Only the first line, as the status takes one.
+		message := strings.SplitN(status.Error, "\n", 2)[0]
+		parts = append(parts, fmt.Sprintf("error: %s", message))
+	}
+	return strings.Join(parts, "  ")
+}
+
+// spin draws again at intervals until the search finishes, turning the
+// spinner.
+func (dm *DrawManager) spin() {
+	ticker := time.NewTicker(SPINNER_INTERVAL)
+	defer ticker.Stop()
+	for range ticker.C {
+		dm.mutex.Lock()
+		if !dm.Options.Search.Status().Searching {
+			dm.spinning = false
+			dm.mutex.Unlock()
+			return
+		}
+		dm.mutex.Unlock()
+		dm.redrawTrigger.Notify()
+	}
+}
+
+// addPreview adds the lines of the preview pane, taking previewRows rows, to
+// the lines of results taking resultRows rows, beside them on the right or
+// below them.
+// When the lines are drawn upwards from the query, the preview pane is added
+// in reverse, so that it still reads downwards.
+func (dm *DrawManager) addPreview(
+	lines []line, preview []PreviewLine,
+	resultRows, previewRows, width int) []line {
+	for len(lines) < resultRows {
+		lines = append(lines, line{index: -1})
+	}
+	previewLine := func(i int) *PreviewLine {
+		if i < len(preview) {
+			return &preview[i]
+		}
+		return &PreviewLine{}
+	}
+	if dm.Options.PreviewPosition == PREVIEW_BOTTOM {
+		separator := line{
+			[]span{{strings.Repeat("─", width), nil}}, nil, -1, nil}
+		pane := []line{}
+		for i := 0; i < previewRows; i++ {
+			pane = append(pane, line{index: -1, preview: previewLine(i)})
+		}
+		if dm.direction() < 0 {
+			for i, j := 0, len(pane)-1; i < j; i, j = i+1, j-1 {
+				pane[i], pane[j] = pane[j], pane[i]
+			}
+		}
+		return append(append(lines, separator), pane...)
+	}
+	for i := range lines {
+		if dm.direction() < 0 {
+			lines[i].preview = previewLine(len(lines) - 1 - i)
+		} else {
+			lines[i].preview = previewLine(i)
+		}
+	}
+	return lines
+}
+
+// direction returns 1 if lines are drawn below the query, or -1 if above.
+func (dm *DrawManager) direction() int {
+	if dm.Options.FullScreen && dm.Options.QueryAtBottom {
+		return -1
+	}
+	return 1
+}
+
+func drawQuery(ansi ANSI, f frame, width int) error {
+	return printLine(ansi, f.query, width, f.queryStyle)
+}
+
+// drawInline draws the query on the line of the cursor with the lines below
+// it, returning whether the screen scrolled.
+func (dm *DrawManager) drawInline(
+	ansi ANSI, f frame, width int) (bool, error) {
+	var err error
+
+	// Scroll the terminal once so that the whole region fits below the
+	// prompt, rather than as results fill it.
+	scrolled := false
+	if dm.Options.Height.N > 0 {
+		_, height := dm.Options.TerminalDimensions.Dimensions()
+		region := dm.Options.Height.Rows(height)
+		if region > dm.maxLines {
+			for i := 1; i < region; i++ {
+				err = ansi.NL()
+				if err != nil {
+					return false, err
+				}
+			}
+			err = ansi.CUU(region - 1)
+			if err != nil {
+				return false, err
+			}
+			dm.maxLines = region
+			scrolled = true
+		}
+	}
+
+	err = drawQuery(ansi, f, width)
+	if err != nil {
+		return false, err
+	}
+
+	for _, l := range f.lines {
 		err = ansi.NL()
 		if err != nil {
-			return err
+			return false, err
 		}
-		err = printLine(ansi, result, width, selection == i)
+		err = drawLine(ansi, f, l, width)
 		if err != nil {
-			return err
+			return false, err
 		}
 	}
 
 	// Clear rest of screen
-	lines := len(results) + 1
+	lines := len(f.lines) + 1
+	// Drawing more lines than before may have scrolled the screen.
 	if lines > dm.maxLines {
 		dm.maxLines = lines
+		scrolled = true
+	}
+	_, height := dm.Options.TerminalDimensions.Dimensions()
+	if height > 0 && dm.maxLines > height {
+		// Lines drawn before the terminal shrank are no longer on screen.
+		dm.maxLines = height
 	}
 	if lines < dm.maxLines {
 		err = ansi.CR()
 		if err != nil {
-			return err
+			return false, err
 		}
 		err = ansi.NL()
 		if err != nil {
-			return err
+			return false, err
 		}
 		lines++
 		err = ansi.ED(0)
 		if err != nil {
-			return err
+			return false, err
 		}
 	}
 
-	// Set cursor at end of query
+	// Set cursor in query
+	err = ansi.CR()
+	if err != nil {
+		return false, err
+	}
 	if lines > 1 {
-		err = ansi.CR()
+		err = ansi.CUU(lines - 1)
 		if err != nil {
-			return err
+			return false, err
 		}
-		err = ansi.CUU(lines - 1)
+	}
+	if f.cursor > 0 {
+		err = ansi.CUF(f.cursor)
+		if err != nil {
+			return false, err
+		}
+	}
+	return scrolled, nil
+}
+
+// queryRow returns the row of the screen the query is drawn on in full screen
+// mode, starting at 1.
+func (dm *DrawManager) queryRow(height int) int {
+	if dm.Options.QueryAtBottom && height > 0 {
+		return height
+	}
+	return 1
+}
+
+// drawFullScreen draws every row of the screen, with the query at the top
+// and the lines below it, or at the bottom and the lines above it.
+func (dm *DrawManager) drawFullScreen(
+	ansi ANSI, f frame, width, height int) error {
+	var err error
+	queryRow := dm.queryRow(height)
+
+	for i := 0; i < height-1; i++ {
+		err = ansi.CUP(queryRow+dm.direction()*(i+1), 1)
 		if err != nil {
 			return err
 		}
-		if len(query) > 0 {
-			err = ansi.CUF(len(query))
-			if err != nil {
-				return err
-			}
+		if i < len(f.lines) {
+			err = drawLine(ansi, f, f.lines[i], width)
+		} else {
+			err = ansi.EL(2)
+		}
+		if err != nil {
+			return err
 		}
 	}
 
-	// Show cursor
-	err = ansi.DECTCEM(true)
+	err = ansi.CUP(queryRow, 1)
 	if err != nil {
 		return err
 	}
+	err = drawQuery(ansi, f, width)
+	if err != nil {
+		return err
+	}
+
+	// Set cursor in query
+	return ansi.CUP(queryRow, f.cursor+1)
+}
+
+type DrawClient struct {
+	dm *DrawManager
+}
 
-	return dm.w.Flush()
+// ResultRows returns the number of rows of results drawn.
+func (dc *DrawClient) ResultRows() int {
+	dc.dm.mutex.RLock()
+	rows := dc.dm.resultRows
+	dc.dm.mutex.RUnlock()
+	return rows
+}
+
+// PreviewRows returns the number of lines of the preview pane drawn, or 0 if
+// it is closed.
+func (dc *DrawClient) PreviewRows() int {
+	dc.dm.mutex.RLock()
+	rows := dc.dm.previewRows
+	dc.dm.mutex.RUnlock()
+	return rows
+}
+
+// ResultAt returns the index of the result drawn on a row of the screen,
+// starting at 1, or -1 for the query.
+func (dc *DrawClient) ResultAt(row int) (int, bool) {
+	origin := dc.dm.Options.TerminalDimensions.Origin()
+	if dc.dm.Options.FullScreen {
+		_, height := dc.dm.Options.TerminalDimensions.Dimensions()
+		origin = dc.dm.queryRow(height)
+	}
+	if origin == 0 {
+		return 0, false
+	}
+	dc.dm.mutex.RLock()
+	i, ok := dc.dm.rendered[row-origin]
+	dc.dm.mutex.RUnlock()
+	return i, ok
 }
@@ -1,5 +1,5 @@
 module github.com/katsuya94/go-notes
 
-go 1.12
+go 1.18
 
 require golang.org/x/sys v0.0.0-20191224085550-c709ea063b76
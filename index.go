@@ -0,0 +1,154 @@
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// listNotes returns the titles of the notes in the notes directory. Nested
+// directories and files without a .txt extension are skipped.
+func listNotes(notesDirectory string) ([]string, error) {
+	titles := []string{}
+	walkFunc := func(p string, info os.FileInfo, err error) error {
+		if p == notesDirectory {
+			if err != nil {
+				return err
+			}
+			return nil
+		}
+		if err != nil {
+			Logger.Print("Error while walking directory: ", err)
+			return nil
+		}
+		if info.IsDir() {
+			Logger.Print("Encountered nested directory")
+			return filepath.SkipDir
+		}
+		base := path.Base(p)
+		title := strings.TrimSuffix(base, ".txt")
+		if title == base {
+			Logger.Printf("Encountered malformed filename: %s", base)
+			return nil
+		}
+		titles = append(titles, title)
+		return nil
+	}
+	err := filepath.Walk(notesDirectory, walkFunc)
+	if err != nil {
+		return nil, err
+	}
+	return titles, nil
+}
+
+// tokenize splits text into lowercase terms made of letters and digits.
+// Single character terms are dropped.
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+	terms := fields[:0]
+	for _, field := range fields {
+		if len([]rune(field)) > 1 {
+			terms = append(terms, field)
+		}
+	}
+	return terms
+}
+
+// vector is the TF-IDF weights of the terms of a note, with its norm.
+type vector struct {
+	weights map[string]float64
+	norm    float64
+}
+
+// Index holds the terms of every note in the notes directory.
+type Index struct {
+	// Term counts of each note, by title.
+	documents map[string]map[string]int
+	// Number of notes containing each term.
+	frequencies map[string]int
+	// Weights of the terms of each note, by title.
+	vectors map[string]vector
+	// Modification times of the notes when indexed, by title.
+	modified map[string]time.Time
+}
+
+func BuildIndex(notesDirectory string) (*Index, error) {
+	titles, err := listNotes(notesDirectory)
+	if err != nil {
+		return nil, err
+	}
+	idx := &Index{
+		map[string]map[string]int{},
+		map[string]int{},
+		map[string]vector{},
+		map[string]time.Time{}}
+	for _, title := range titles {
+		p := path.Join(notesDirectory, title+".txt")
+		info, err := os.Stat(p)
+		if err != nil {
+			Logger.Print("Error while reading note: ", err)
+			continue
+		}
+		idx.modified[title] = info.ModTime()
+		contents, err := ioutil.ReadFile(p)
+		if err != nil {
+			Logger.Print("Error while reading note: ", err)
+			continue
+		}
+		counts := map[string]int{}
+		for _, term := range tokenize(title) {
+			counts[term]++
+		}
+		for _, term := range tokenize(string(contents)) {
+			counts[term]++
+		}
+		idx.documents[title] = counts
+		for term := range counts {
+			idx.frequencies[term]++
+		}
+	}
+	n := float64(len(idx.documents))
+	for title, counts := range idx.documents {
+		v := vector{make(map[string]float64, len(counts)), 0}
+		for term, count := range counts {
+			idf := math.Log(n / float64(idx.frequencies[term]))
+			weight := (1 + math.Log(float64(count))) * idf
+			if weight == 0 {
+				continue
+			}
+			v.weights[term] = weight
+			v.norm += weight * weight
+		}
+		v.norm = math.Sqrt(v.norm)
+		idx.vectors[title] = v
+	}
+	Logger.Print("Indexed ", len(idx.documents), " notes")
+	return idx, nil
+}
+
+// Current returns whether the index is of the notes as they are, with no note
+// added, removed or modified since it was built.
+func (idx *Index) Current(notesDirectory string) bool {
+	titles, err := listNotes(notesDirectory)
+	if err != nil || len(titles) != len(idx.modified) {
+		return false
+	}
+	for _, title := range titles {
+		modified, ok := idx.modified[title]
+		if !ok {
+			return false
+		}
+		info, err := os.Stat(path.Join(notesDirectory, title+".txt"))
+		if err != nil || !info.ModTime().Equal(modified) {
+			return false
+		}
+	}
+	return true
+}
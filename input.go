@@ -1,146 +1,319 @@
 package main
 
 import (
-	"bufio"
-	"errors"
 	"fmt"
 	"io"
+	"sync"
+	"syscall"
+	"time"
 	"unicode/utf8"
 )
 
+// DEC private mode wrapping pasted text in CSI 200~ and CSI 201~.
+const BRACKETED_PASTE_MODE = 2004
+
+// DEC private mode reporting changes of the dimensions of the terminal as
+// CSI 48 ; <height> ; <width> ; ... t.
+const IN_BAND_RESIZE_MODE = 2048
+
+// Mouse buttons, as reported in SGR mouse sequences.
 const (
-	ESC = 0x1b
-	CSI = 0x5b
-	DEL = 0x7f
+	MOUSE_LEFT       = 0
+	MOUSE_WHEEL_UP   = 64
+	MOUSE_WHEEL_DOWN = 65
 )
 
-var ErrInvalidEscapeSequence = errors.New("invalid escape sequence")
+// Two clicks on a result within this interval open it.
+const DOUBLE_CLICK_INTERVAL = 400 * time.Millisecond
 
 type InputManagerOptions struct {
 	Reader             io.Reader
+	Writer             io.Writer
 	Search             *SearchClient
 	TerminalDimensions *TerminalDimensionsClient
+	Draw               *DrawClient
+	Menu               *MenuClient
+	Preview            *PreviewClient
+	KeyMap             KeyMap
+	// Receives when the user aborts without opening a note.
+	Abort chan<- struct{}
+	// Whether the query is edited with vi commands.
+	ViMode bool
 }
 
 type InputManager struct {
 	Options InputManagerOptions
-	reader  io.ByteReader
+	// The vi editing mode, or "" if vi mode is disabled.
+	mode string
+	// The vi operator waiting for a motion, or 0.
+	operator rune
+	// Whether the keyboard protocol was enabled.
+	keyboardProtocol bool
+	// The result last clicked on and when, to detect double clicks.
+	lastClick     int
+	lastClickTime time.Time
+	trigger       *Trigger
+	mutex         *sync.RWMutex
 }
 
 func NewInputManager(options InputManagerOptions) *InputManager {
-	return &InputManager{options, nil}
+	return &InputManager{
+		options,
+		"", 0,
+		false,
+		0, time.Time{},
+		NewTrigger(),
+		&sync.RWMutex{}}
 }
 
-func (im *InputManager) handleEscapeSequence() error {
-	var b byte
-	var err error
-	// Read the second byte.
-	b, err = im.reader.ReadByte()
+func (im *InputManager) Client() *InputClient {
+	return &InputClient{im}
+}
+
+func (im *InputManager) notify() {
+	Logger.Print("InputManager Notify")
+	im.trigger.Notify()
+}
+
+// Cleanup disables the terminal modes enabled by Start.
+func (im *InputManager) Cleanup() error {
+	ansi := ANSI{im.Options.Writer}
+	im.mutex.RLock()
+	keyboardProtocol := im.keyboardProtocol
+	im.mutex.RUnlock()
+	if keyboardProtocol {
+		err := ansi.PopKeyboardFlags()
+		if err != nil {
+			return err
+		}
+		im.mutex.Lock()
+		im.keyboardProtocol = false
+		im.mutex.Unlock()
+	}
+	err := ansi.DECRST(IN_BAND_RESIZE_MODE)
 	if err != nil {
 		return err
 	}
-	if !(b >= 0x40 && b <= 0x5f) {
-		return ErrInvalidEscapeSequence
-	}
-	if b != CSI {
-		// Not a CSI sequence. We're done.
-		return nil
+	return ansi.DECRST(BRACKETED_PASTE_MODE)
+}
+
+// Resume enables the terminal modes again after Cleanup.
+func (im *InputManager) Resume() error {
+	ansi := ANSI{im.Options.Writer}
+	err := ansi.DECSET(BRACKETED_PASTE_MODE)
+	if err != nil {
+		return err
 	}
-	b, err = im.reader.ReadByte()
+	err = ansi.DECSET(IN_BAND_RESIZE_MODE)
 	if err != nil {
 		return err
 	}
-	// Read the parameter bytes.
-	parameterBytes := []byte{}
-	for {
-		if !(b >= 0x30 && b <= 0x3f) {
-			break
+	return im.requestKeyboardProtocol()
+}
+
+// Mode returns the vi editing mode, or "" if vi mode is disabled.
+func (im *InputManager) Mode() string {
+	im.mutex.RLock()
+	mode := im.mode
+	im.mutex.RUnlock()
+	return mode
+}
+
+func (im *InputManager) handleEvent(event Event) error {
+	switch event := event.(type) {
+	case RuneEvent:
+		im.handleRune(event.Rune)
+	case KeyEvent:
+		if im.Options.ViMode && event.Modifiers == MOD_ALT &&
+			utf8.RuneCountInString(event.Name) == 1 &&
+			!im.Options.Menu.IsOpen() {
+			// In vi mode, Escape followed by a key is not an Alt modified
+			// key, and never aborts.
+			im.handleViEscape()
+			im.handleRune([]rune(event.Name)[0])
+			return nil
 		}
-		parameterBytes = append(parameterBytes, b)
-		b, err = im.reader.ReadByte()
-		if err != nil {
-			return err
+		im.handleKey(event.String())
+	case PasteEvent:
+		im.handlePaste(event.Text)
+	case MouseEvent:
+		if event.Press && !event.Motion && !im.Options.Menu.IsOpen() {
+			im.handleMousePress(event.Button, event.Y)
 		}
+	case CursorPositionEvent:
+		im.Options.TerminalDimensions.ReportCursorPosition(
+			event.Row, event.Column)
+	case ResizeEvent:
+		im.Options.TerminalDimensions.SetDimensions(
+			event.Height, event.Width)
+	case KeyboardFlagsEvent:
+		return im.enableKeyboardProtocol()
+	case DeviceAttributesEvent:
+		im.handleDeviceAttributes()
 	}
-	Logger.Print("Escape sequence parameter bytes ", string(parameterBytes))
-	// Read the intermediate bytes.
-	for {
-		if !(b >= 0x20 && b <= 0x2f) {
-			break
+	return nil
+}
+
+// handlePaste inserts text pasted in bracketed paste mode into the query,
+// collapsing line breaks into spaces so that they are not taken as Enter.
+func (im *InputManager) handlePaste(pasted string) {
+	Logger.Print("Pasted ", len(pasted), " bytes")
+	text := []rune{}
+	newline := false
+	for _, c := range pasted {
+		switch {
+		case c == '\r' || c == '\n':
+			newline = true
+			continue
+		case c == '\t':
+			c = ' '
+		case c < 0x20 || c == DEL:
+			continue
 		}
-		b, err = im.reader.ReadByte()
-		if err != nil {
-			return err
+		if newline && len(text) > 0 && text[len(text)-1] != ' ' && c != ' ' {
+			text = append(text, ' ')
 		}
+		newline = false
+		text = append(text, c)
 	}
-	// Read the final byte.
-	if !(b >= 0x40 && b <= 0x7e) {
-		return ErrInvalidEscapeSequence
-	}
-	switch b {
-	case 'A':
-		im.Options.Search.SelectPrevious()
-	case 'B':
-		im.Options.Search.SelectNext()
-	case 'R':
-		var n, m int
-		_, err := fmt.Sscanf(string(parameterBytes), "%d;%d", &n, &m)
-		if err != nil {
-			return err
+	insert := func(le *LineEditor) {
+		le.Insert(text...)
+	}
+	if im.Options.Menu.IsOpen() {
+		im.Options.Menu.Edit(insert)
+		return
+	}
+	im.Options.Search.Edit(insert)
+}
+
+// handleMousePress selects the result clicked on, opening it on a double
+// click, or moves the selection with the wheel.
+func (im *InputManager) handleMousePress(button, row int) {
+	search := im.Options.Search
+	switch button {
+	case MOUSE_WHEEL_UP:
+		search.SelectPrevious()
+	case MOUSE_WHEEL_DOWN:
+		search.SelectNext()
+	case MOUSE_LEFT:
+		i, ok := im.Options.Draw.ResultAt(row)
+		if !ok {
+			return
+		}
+		now := time.Now()
+		double := i != -1 && i == im.lastClick &&
+			now.Sub(im.lastClickTime) < DOUBLE_CLICK_INTERVAL
+		im.lastClick, im.lastClickTime = i, now
+		search.SetSelection(i)
+		if double {
+			im.accept(OPEN_EDITOR)
 		}
-		im.Options.TerminalDimensions.SetDimensions(n, m)
 	}
-	return nil
+}
+
+// handleKey performs the action bound to a key.
+func (im *InputManager) handleKey(key string) {
+	action, ok := im.Options.KeyMap[key]
+	if ok && im.Options.Menu.IsOpen() {
+		im.performMenu(action)
+		return
+	}
+	if key == "esc" && im.Options.ViMode && im.Mode() == MODE_INSERT {
+		im.handleViEscape()
+		return
+	}
+	if key == "esc" && im.Options.ViMode && im.cancelViOperator() {
+		return
+	}
+	if !ok && SIGNAL_KEYS[key] != 0 {
+		// Behave as in legacy mode.
+		Logger.Print("Raising signal for ", key)
+		syscall.Kill(syscall.Getpid(), SIGNAL_KEYS[key])
+		return
+	}
+	if !ok {
+		Logger.Print("Unbound key ", key)
+		return
+	}
+	im.perform(action)
 }
 
 func (im *InputManager) handleRune(c rune) {
-	switch c {
-	case DEL:
-		im.Options.Search.Backspace()
-	case '\r':
-		im.Options.Search.Select()
-	default:
-		im.Options.Search.Append(c)
+	key := runeKey(c, 0).String()
+	if _, ok := im.Options.KeyMap[key]; ok || c < 0x20 || c == DEL {
+		im.handleKey(key)
+		return
+	}
+	if im.Options.Menu.IsOpen() {
+		im.Options.Menu.HandleRune(c)
+		return
+	}
+	if im.Mode() == MODE_NORMAL {
+		im.handleViNormal(c)
+		return
 	}
+	im.Options.Search.Insert(c)
 }
 
-// TODO: refactor into RuneReader implementation that ignores escape sequences
 func (im *InputManager) Start() error {
 	if im.Options.Reader == nil {
 		return fmt.Errorf("no Reader")
 	}
+	if im.Options.Writer == nil {
+		return fmt.Errorf("no Writer")
+	}
 	if im.Options.Search == nil {
 		return fmt.Errorf("no Search")
 	}
 	if im.Options.TerminalDimensions == nil {
 		return fmt.Errorf("no TerminalDimensions")
 	}
-	im.reader = bufio.NewReader(im.Options.Reader)
-	var (
-		buf = make([]byte, 0, 4)
-		b   byte
-		err error
-	)
+	if im.Options.Draw == nil {
+		return fmt.Errorf("no Draw")
+	}
+	if im.Options.Menu == nil {
+		return fmt.Errorf("no Menu")
+	}
+	if im.Options.Preview == nil {
+		return fmt.Errorf("no Preview")
+	}
+	if im.Options.KeyMap == nil {
+		return fmt.Errorf("no KeyMap")
+	}
+	if im.Options.Abort == nil {
+		return fmt.Errorf("no Abort")
+	}
+	decoder := NewDecoder(im.Options.Reader)
+	err := im.Resume()
+	if err != nil {
+		return err
+	}
+	if im.Options.ViMode {
+		im.setMode(MODE_INSERT)
+	}
 	Logger.Print("Starting InputManager")
 	for {
-		b, err = im.reader.ReadByte()
+		event, err := decoder.Next()
 		if err != nil {
 			return err
 		}
-		if b == ESC {
-			Logger.Print("Start handling escape sequence")
-			err = im.handleEscapeSequence()
-			if err != nil {
-				return err
-			}
-			continue
-		}
-		buf = append(buf, b)
-		if utf8.FullRune(buf) {
-			Logger.Print("Received input")
-			c, _ := utf8.DecodeRune(buf)
-			buf = buf[:0]
-			im.handleRune(c)
+		Logger.Printf("Received %#v", event)
+		err = im.handleEvent(event)
+		if err != nil {
+			return err
 		}
 	}
 }
+
+type InputClient struct {
+	im *InputManager
+}
+
+// Mode returns the vi editing mode, or "" if vi mode is disabled.
+func (ic *InputClient) Mode() string {
+	return ic.im.Mode()
+}
+
+func (ic *InputClient) Subscribe() Subscription {
+	return ic.im.trigger.Subscribe()
+}
@@ -0,0 +1,55 @@
+package main
+
+import "syscall"
+
+// Flag of the progressive enhancement keyboard protocol making the terminal
+// report ambiguous keys, such as Ctrl-I and Tab, as distinct CSI u
+// sequences.
+const KEYBOARD_DISAMBIGUATE = 1
+
+// Keys the terminal turns into signals in legacy mode, which are sent as
+// keys once the keyboard protocol is enabled.
+var SIGNAL_KEYS = map[string]syscall.Signal{
+	"ctrl-c":  syscall.SIGINT,
+	"ctrl-z":  syscall.SIGTSTP,
+	"ctrl-\\": syscall.SIGQUIT,
+}
+
+// requestKeyboardProtocol asks whether the terminal supports the keyboard
+// protocol. Terminals that do answer the query before answering the request
+// for device attributes, which every terminal answers.
+func (im *InputManager) requestKeyboardProtocol() error {
+	ansi := ANSI{im.Options.Writer}
+	err := ansi.QueryKeyboardFlags()
+	if err != nil {
+		return err
+	}
+	return ansi.DA()
+}
+
+// enableKeyboardProtocol enables the keyboard protocol once the terminal has
+// answered the query.
+func (im *InputManager) enableKeyboardProtocol() error {
+	im.mutex.Lock()
+	enabled := im.keyboardProtocol
+	im.keyboardProtocol = true
+	im.mutex.Unlock()
+	if enabled {
+		return nil
+	}
+	Logger.Print("Enabling keyboard protocol")
+	ansi := ANSI{im.Options.Writer}
+	return ansi.PushKeyboardFlags(KEYBOARD_DISAMBIGUATE)
+}
+
+// handleDeviceAttributes handles the answer to the request for device
+// attributes, which comes after the answer to the keyboard protocol query if
+// the protocol is supported.
+func (im *InputManager) handleDeviceAttributes() {
+	im.mutex.RLock()
+	keyboardProtocol := im.keyboardProtocol
+	im.mutex.RUnlock()
+	if !keyboardProtocol {
+		Logger.Print("Keyboard protocol not supported, using legacy keys")
+	}
+}
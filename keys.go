@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Keys are named by a canonical string: the modifiers "ctrl-", "alt-" and
+// "shift-", in that order, followed by either a character or the name of a
+// special key. Escape sequences with no name are written as "\e" followed by
+// the rest of the sequence, e.g. "\e[1;5C".
+
+// Names of special keys.
+var KEY_NAMES = []string{
+	"enter", "tab", "backspace", "esc", "space",
+	"up", "down", "left", "right",
+	"home", "end", "pgup", "pgdn", "insert", "delete",
+	"f1", "f2", "f3", "f4", "f5", "f6", "f7", "f8", "f9", "f10", "f11", "f12",
+}
+
+// Alternative names of special keys.
+var KEY_ALIASES = map[string]string{
+	"return":    "enter",
+	"escape":    "esc",
+	"bspace":    "backspace",
+	"bs":        "backspace",
+	"pageup":    "pgup",
+	"page-up":   "pgup",
+	"pagedown":  "pgdn",
+	"page-down": "pgdn",
+	"del":       "delete",
+	"ins":       "insert",
+	"btab":      "shift-tab",
+}
+
+// Modifiers is a set of modifier keys held with a key.
+type Modifiers int
+
+const (
+	MOD_SHIFT Modifiers = 1 << iota
+	MOD_ALT
+	MOD_CTRL
+)
+
+var MODIFIER_ALIASES = map[string]Modifiers{
+	"ctrl":    MOD_CTRL,
+	"control": MOD_CTRL,
+	"c":       MOD_CTRL,
+	"alt":     MOD_ALT,
+	"meta":    MOD_ALT,
+	"m":       MOD_ALT,
+	"a":       MOD_ALT,
+	"shift":   MOD_SHIFT,
+	"s":       MOD_SHIFT,
+}
+
+// keyName returns the canonical name of a key with modifiers.
+func keyName(modifiers Modifiers, name string) string {
+	var sb strings.Builder
+	if modifiers&MOD_CTRL != 0 {
+		sb.WriteString("ctrl-")
+	}
+	if modifiers&MOD_ALT != 0 {
+		sb.WriteString("alt-")
+	}
+	if modifiers&MOD_SHIFT != 0 {
+		sb.WriteString("shift-")
+	}
+	sb.WriteString(name)
+	return sb.String()
+}
+
+// ParseKey returns the canonical name of a key chord such as "C-a",
+// "Alt-Enter" or "\e[1;5C". Escape sequences are named as the decoder names
+// the key they encode, so "\e[1;5C" is "ctrl-right".
+func ParseKey(s string) (string, error) {
+	if strings.HasPrefix(s, "\\e") || strings.HasPrefix(s, "\x1b") {
+		return parseSequence(s)
+	}
+	var modifiers Modifiers
+	name := s
+	for {
+		i := strings.Index(name, "-")
+		if i <= 0 || i == len(name)-1 {
+			break
+		}
+		modifier, ok := MODIFIER_ALIASES[strings.ToLower(name[:i])]
+		if !ok {
+			break
+		}
+		modifiers |= modifier
+		name = name[i+1:]
+	}
+	if name == " " {
+		name = "space"
+	}
+	if utf8.RuneCountInString(name) == 1 {
+		if modifiers&MOD_CTRL != 0 {
+			// Control characters are case insensitive.
+			name = strings.ToLower(name)
+		}
+		return keyName(modifiers, name), nil
+	}
+	name = strings.ToLower(name)
+	if alias, ok := KEY_ALIASES[name]; ok {
+		name = alias
+	}
+	if strings.HasPrefix(name, "shift-") {
+		modifiers |= MOD_SHIFT
+		name = strings.TrimPrefix(name, "shift-")
+	}
+	for _, known := range KEY_NAMES {
+		if name == known {
+			return keyName(modifiers, name), nil
+		}
+	}
+	return "", fmt.Errorf("unknown key %q", s)
+}
+
+// parseSequence returns the canonical name of the key sent as an escape
+// sequence, with the escape written as "\e" or as itself.
+func parseSequence(s string) (string, error) {
+	sequence := "\x1b" + strings.TrimPrefix(
+		strings.TrimPrefix(s, "\\e"), "\x1b")
+	decoder := NewDecoder(strings.NewReader(sequence))
+	// Read until the end, so that the decoder stops reading.
+	events := []Event{}
+	for {
+		event, err := decoder.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		events = append(events, event)
+	}
+	// The sequence must be a single key.
+	if len(events) != 1 {
+		return "", fmt.Errorf("unknown key %q", s)
+	}
+	key, ok := events[0].(KeyEvent)
+	if !ok {
+		return "", fmt.Errorf("unknown key %q", s)
+	}
+	return key.String(), nil
+}
+
+// KeyMap binds keys, by canonical name, to actions.
+type KeyMap map[string]Action
+
+// DEFAULT_KEY_BINDINGS accept with Ctrl rather than Alt and a letter, which in
+// vi mode is Escape followed by the letter.
+var DEFAULT_KEY_BINDINGS = map[string]Action{
+	"enter":      ACTION_ACCEPT,
+	"alt-enter":  ACTION_ACCEPT_PAGER,
+	"ctrl-p":     ACTION_ACCEPT_PRINT_PATH,
+	"ctrl-x":     ACTION_ACCEPT_COPY_PATH,
+	"ctrl-t":     ACTION_ACCEPT_COPY_CONTENTS,
+	"esc":        ACTION_ABORT,
+	"ctrl-g":     ACTION_ABORT,
+	"ctrl-c":     ACTION_ABORT,
+	"up":         ACTION_SELECT_PREVIOUS,
+	"down":       ACTION_SELECT_NEXT,
+	"pgup":       ACTION_PAGE_UP,
+	"pgdn":       ACTION_PAGE_DOWN,
+	"ctrl-home":  ACTION_FIRST,
+	"ctrl-end":   ACTION_LAST,
+	"alt-<":      ACTION_FIRST,
+	"alt->":      ACTION_LAST,
+	"ctrl-r":     ACTION_TOGGLE_RELATED,
+	"tab":        ACTION_TOGGLE_MARK_NEXT,
+	"shift-tab":  ACTION_TOGGLE_MARK_PREVIOUS,
+	"ctrl-o":     ACTION_MENU,
+	"ctrl-v":     ACTION_TOGGLE_PREVIEW,
+	"shift-up":   ACTION_PREVIEW_UP,
+	"shift-down": ACTION_PREVIEW_DOWN,
+	"shift-pgup": ACTION_PREVIEW_PAGE_UP,
+	"shift-pgdn": ACTION_PREVIEW_PAGE_DOWN,
+	"left":       ACTION_BACKWARD_CHAR,
+	"ctrl-b":     ACTION_BACKWARD_CHAR,
+	"right":      ACTION_FORWARD_CHAR,
+	"ctrl-f":     ACTION_FORWARD_CHAR,
+	"home":       ACTION_HOME,
+	"ctrl-a":     ACTION_BEGINNING_OF_LINE,
+	"end":        ACTION_END,
+	"ctrl-e":     ACTION_END_OF_LINE,
+	"alt-b":      ACTION_BACKWARD_WORD,
+	"alt-f":      ACTION_FORWARD_WORD,
+	"backspace":  ACTION_BACKWARD_DELETE_CHAR,
+	"ctrl-h":     ACTION_BACKWARD_DELETE_CHAR,
+	"delete":     ACTION_DELETE_CHAR,
+	"ctrl-d":     ACTION_DELETE_CHAR,
+	"ctrl-w":     ACTION_BACKWARD_DELETE_WORD,
+	"alt-d":      ACTION_DELETE_WORD,
+	"ctrl-u":     ACTION_BACKWARD_KILL_LINE,
+	"ctrl-k":     ACTION_KILL_LINE,
+	"ctrl-y":     ACTION_YANK,
+}
+
+// NewKeyMap returns the default key map overridden by the given bindings from
+// key chords to action names. Unknown keys or actions and keys bound to
+// different actions under several names are reported together.
+func NewKeyMap(bindings map[string]string) (KeyMap, error) {
+	keyMap := KeyMap{}
+	for key, action := range DEFAULT_KEY_BINDINGS {
+		keyMap[key] = action
+	}
+	// Sort the bindings so that problems are reported in a stable order.
+	chords := make([]string, 0, len(bindings))
+	for chord := range bindings {
+		chords = append(chords, chord)
+	}
+	sort.Strings(chords)
+	problems := []string{}
+	boundBy := map[string]string{}
+	for _, chord := range chords {
+		action := Action(bindings[chord])
+		key, err := ParseKey(chord)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if !isAction(action) {
+			problems = append(problems, fmt.Sprintf(
+				"unknown action %q for key %q", action, chord))
+			continue
+		}
+		if other, ok := boundBy[key]; ok && Action(bindings[other]) != action {
+			problems = append(problems, fmt.Sprintf(
+				"key %q bound to %q conflicts with %q bound to %q",
+				chord, action, other, bindings[other]))
+			continue
+		}
+		boundBy[key] = chord
+		if action == ACTION_IGNORE {
+			delete(keyMap, key)
+			continue
+		}
+		keyMap[key] = action
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf(
+			"invalid key bindings: %s", strings.Join(problems, "; "))
+	}
+	return keyMap, nil
+}
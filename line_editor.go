@@ -0,0 +1,184 @@
+package main
+
+import "unicode"
+
+// LineEditor holds a line of text being edited, the position of the cursor
+// within it and the text last killed.
+type LineEditor struct {
+	runes  []rune
+	cursor int
+	killed []rune
+}
+
+func (le *LineEditor) String() string {
+	return string(le.runes)
+}
+
+// Cursor returns the position of the cursor in runes.
+func (le *LineEditor) Cursor() int {
+	return le.cursor
+}
+
+func (le *LineEditor) Len() int {
+	return len(le.runes)
+}
+
+// Insert inserts text before the cursor.
+func (le *LineEditor) Insert(text ...rune) {
+	runes := make([]rune, 0, len(le.runes)+len(text))
+	runes = append(runes, le.runes[:le.cursor]...)
+	runes = append(runes, text...)
+	runes = append(runes, le.runes[le.cursor:]...)
+	le.runes = runes
+	le.cursor += len(text)
+}
+
+// Set replaces the text, moving the cursor to the end.
+func (le *LineEditor) Set(text string) {
+	le.runes = []rune(text)
+	le.cursor = len(le.runes)
+}
+
+// MoveTo moves the cursor to a position, clamped to the line.
+func (le *LineEditor) MoveTo(position int) {
+	if position < 0 {
+		position = 0
+	}
+	if position > len(le.runes) {
+		position = len(le.runes)
+	}
+	le.cursor = position
+}
+
+// Remove removes the text between two positions, in any order, and moves the
+// cursor to where it started.
+func (le *LineEditor) Remove(from, to int) []rune {
+	if from > to {
+		from, to = to, from
+	}
+	if from < 0 {
+		from = 0
+	}
+	if to > len(le.runes) {
+		to = len(le.runes)
+	}
+	removed := make([]rune, to-from)
+	copy(removed, le.runes[from:to])
+	le.runes = append(le.runes[:from], le.runes[to:]...)
+	le.cursor = from
+	return removed
+}
+
+// Kill removes the text between two positions, saving it to be yanked.
+func (le *LineEditor) Kill(from, to int) {
+	if from == to {
+		return
+	}
+	le.killed = le.Remove(from, to)
+}
+
+func isWordRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_'
+}
+
+// WordStart returns the position of the start of the word before the
+// cursor.
+func (le *LineEditor) WordStart() int {
+	i := le.cursor
+	for i > 0 && !isWordRune(le.runes[i-1]) {
+		i--
+	}
+	for i > 0 && isWordRune(le.runes[i-1]) {
+		i--
+	}
+	return i
+}
+
+// WordEnd returns the position of the end of the word after the cursor.
+func (le *LineEditor) WordEnd() int {
+	i := le.cursor
+	for i < len(le.runes) && !isWordRune(le.runes[i]) {
+		i++
+	}
+	for i < len(le.runes) && isWordRune(le.runes[i]) {
+		i++
+	}
+	return i
+}
+
+// FieldStart returns the position of the start of the whitespace delimited
+// field before the cursor.
+func (le *LineEditor) FieldStart() int {
+	i := le.cursor
+	for i > 0 && unicode.IsSpace(le.runes[i-1]) {
+		i--
+	}
+	for i > 0 && !unicode.IsSpace(le.runes[i-1]) {
+		i--
+	}
+	return i
+}
+
+func (le *LineEditor) Left() {
+	le.MoveTo(le.cursor - 1)
+}
+
+func (le *LineEditor) Right() {
+	le.MoveTo(le.cursor + 1)
+}
+
+func (le *LineEditor) Home() {
+	le.MoveTo(0)
+}
+
+func (le *LineEditor) End() {
+	le.MoveTo(len(le.runes))
+}
+
+func (le *LineEditor) WordBackward() {
+	le.MoveTo(le.WordStart())
+}
+
+func (le *LineEditor) WordForward() {
+	le.MoveTo(le.WordEnd())
+}
+
+// Backspace removes the rune before the cursor.
+func (le *LineEditor) Backspace() {
+	if le.cursor > 0 {
+		le.Remove(le.cursor-1, le.cursor)
+	}
+}
+
+// Delete removes the rune under the cursor.
+func (le *LineEditor) Delete() {
+	if le.cursor < len(le.runes) {
+		le.Remove(le.cursor, le.cursor+1)
+	}
+}
+
+// KillToStart kills the text before the cursor.
+func (le *LineEditor) KillToStart() {
+	le.Kill(0, le.cursor)
+}
+
+// KillToEnd kills the text after the cursor.
+func (le *LineEditor) KillToEnd() {
+	le.Kill(le.cursor, len(le.runes))
+}
+
+// KillFieldBackward kills the whitespace delimited field before the cursor.
+func (le *LineEditor) KillFieldBackward() {
+	le.Kill(le.FieldStart(), le.cursor)
+}
+
+// KillWordForward kills the text up to the end of the word after the
+// cursor.
+func (le *LineEditor) KillWordForward() {
+	le.Kill(le.cursor, le.WordEnd())
+}
+
+// Yank inserts the text last killed.
+func (le *LineEditor) Yank() {
+	le.Insert(le.killed...)
+}
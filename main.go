@@ -1,30 +1,63 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path"
 	"syscall"
 )
 
 const DEFAULT_EDITOR = "vim"
 
+// Editors accepting "+<line>" to open a file at a line.
+var LINE_ARGUMENT_EDITORS = []string{
+	"vi", "vim", "nvim", "gvim", "view", "ex", "nano", "emacs", "emacsclient"}
+
+// Editors accepting "-p" to open several files in tabs.
+var TAB_ARGUMENT_EDITORS = []string{"vim", "nvim", "gvim", "view"}
+
+// Exit status when the user aborts, as when killed by SIGINT.
+const ABORT_EXIT_STATUS = 130
+
+// ErrAborted is returned by Run when the user aborts without opening a note.
+var ErrAborted = errors.New("aborted")
+
 var Logger *log.Logger
 
 func Run() error {
 	var (
-		config   *Config
-		notePath string
-		err      error
+		config *Config
+		choice Choice
+		err    error
 	)
 
+	heightFlag := flag.String(
+		"height", "",
+		"draw in a region of `rows` below the prompt, or of a percentage of "+
+			"the terminal such as 40%")
+	flag.Parse()
+	var height Height
+	if *heightFlag != "" {
+		height, err = ParseHeight(*heightFlag)
+		if err != nil {
+			return err
+		}
+	}
+
 	config, err = LoadConfig()
 	if err != nil {
 		return err
 	}
+	// A height given on the command line takes precedence over FullScreen.
+	if height.N > 0 {
+		config.FullScreen = false
+	}
 
 	if config.LogFile == "" {
 		Logger = log.New(ioutil.Discard, "", 0)
@@ -37,17 +70,31 @@ func Run() error {
 		Logger = log.New(file, "", log.Ldate|log.Ltime|log.Lshortfile)
 	}
 
-	err = WithTerminalAttributes(func() error {
+	keyMap, err := NewKeyMap(config.KeyBindings)
+	if err != nil {
+		return err
+	}
+
+	theme, err := NewTheme(config.Theme, ColorDepth())
+	if err != nil {
+		return err
+	}
+
+	err = WithTerminalAttributes(func(ta *TerminalAttributes) error {
 		fail := make(chan error)
 		die := make(chan interface{})
 
-		quit := make(chan os.Signal)
+		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
 
-		winch := make(chan os.Signal)
+		winch := make(chan os.Signal, 1)
 		signal.Notify(winch, syscall.SIGWINCH)
 
-		selection := make(chan string)
+		tstp := make(chan os.Signal, 1)
+		signal.Notify(tstp, syscall.SIGTSTP)
+
+		selection := make(chan Choice)
+		abort := make(chan struct{})
 
 		Logger.Print("Initializing managers")
 		searchManager := NewSearchManager(
@@ -55,17 +102,57 @@ func Run() error {
 		terminalDimensionsManager := NewTerminalDimensionsManager(
 			TerminalDimensionsManagerOptions{Writer: os.Stdout})
 		drawManager := NewDrawManager(DrawManagerOptions{Writer: os.Stdout})
-		inputManager := NewInputManager(InputManagerOptions{Reader: os.Stdin})
+		menuManager := NewMenuManager(MenuManagerOptions{Writer: os.Stdout})
+		previewManager := NewPreviewManager(PreviewManagerOptions{})
+		inputManager := NewInputManager(
+			InputManagerOptions{Reader: os.Stdin, Writer: os.Stdout})
 
 		searchManager.Options.NotesDirectory = config.NotesDirectory
+		searchManager.Options.ExpandLines = config.ExpandLines
 		terminalDimensionsManager.Options.WinchSubscription =
 			NewSignalSubscription(winch)
 		drawManager.Options.Search = searchManager.Client()
 		drawManager.Options.TerminalDimensions =
 			terminalDimensionsManager.Client()
+		drawManager.Options.Input = inputManager.Client()
+		drawManager.Options.Menu = menuManager.Client()
+		drawManager.Options.Preview = previewManager.Client()
+		if config.PreviewPosition == "bottom" {
+			drawManager.Options.PreviewPosition = PREVIEW_BOTTOM
+		}
+		drawManager.Options.FullScreen = config.FullScreen
+		drawManager.Options.QueryAtBottom = config.QueryPosition == "bottom"
+		drawManager.Options.Height = height
+		drawManager.Options.Theme = theme
+		menuManager.Options.Search = searchManager.Client()
+		menuManager.Options.NotesDirectory = config.NotesDirectory
+		previewManager.Options.Search = searchManager.Client()
+		previewManager.Options.NotesDirectory = config.NotesDirectory
 		inputManager.Options.Search = searchManager.Client()
 		inputManager.Options.TerminalDimensions =
 			terminalDimensionsManager.Client()
+		inputManager.Options.Draw = drawManager.Client()
+		inputManager.Options.Menu = menuManager.Client()
+		inputManager.Options.Preview = previewManager.Client()
+		inputManager.Options.KeyMap = keyMap
+		inputManager.Options.Abort = abort
+		inputManager.Options.ViMode = config.EditingMode == "vi"
+
+		if config.FullScreen {
+			err := EnterAlternateScreen(os.Stdout)
+			if err != nil {
+				return err
+			}
+			defer ExitAlternateScreen(os.Stdout)
+		}
+
+		if !config.DisableMouse {
+			err := EnableMouse(os.Stdout)
+			if err != nil {
+				return err
+			}
+			defer DisableMouse(os.Stdout)
+		}
 
 		Logger.Print("Starting managers")
 
@@ -83,24 +170,113 @@ func Run() error {
 		start(searchManager.Start)
 		start(terminalDimensionsManager.Start)
 		start(drawManager.Start)
+		start(previewManager.Start)
 		start(inputManager.Start)
 
 		defer drawManager.Cleanup()
+		defer inputManager.Cleanup()
 
-		select {
-		case err := <-fail:
-			return err
-		case <-quit:
-			return nil
-		case notePath = <-selection:
+		// suspend leaves the terminal as it was before starting, stops the
+		// process, and takes the terminal over again once continued.
+		suspend := func() error {
+			Logger.Print("Suspending")
+			err := inputManager.Cleanup()
+			if err != nil {
+				return err
+			}
+			if !config.DisableMouse {
+				err = DisableMouse(os.Stdout)
+				if err != nil {
+					return err
+				}
+			}
+			err = drawManager.Cleanup()
+			if err != nil {
+				return err
+			}
+			if config.FullScreen {
+				err = ExitAlternateScreen(os.Stdout)
+				if err != nil {
+					return err
+				}
+			}
+			err = ta.Restore()
+			if err != nil {
+				return err
+			}
+			cont := make(chan os.Signal, 1)
+			signal.Notify(cont, syscall.SIGCONT)
+			defer signal.Stop(cont)
+			// Once notified of SIGTSTP, the Go runtime never takes its default
+			// action again, so stop with SIGSTOP instead.
+			err = syscall.Kill(syscall.Getpid(), syscall.SIGSTOP)
+			if err != nil {
+				return err
+			}
+			<-cont
+			Logger.Print("Resuming")
+			err = ta.Raw()
+			if err != nil {
+				return err
+			}
+			if config.FullScreen {
+				err = EnterAlternateScreen(os.Stdout)
+				if err != nil {
+					return err
+				}
+			}
+			if !config.DisableMouse {
+				err = EnableMouse(os.Stdout)
+				if err != nil {
+					return err
+				}
+			}
+			err = inputManager.Resume()
+			if err != nil {
+				return err
+			}
+			drawManager.Resume()
+			// The dimensions may have changed while stopped.
+			terminalDimensionsManager.Client().RequestOrigin()
 			return nil
 		}
+
+		for {
+			select {
+			case err := <-fail:
+				return err
+			case r := <-die:
+				// Panic here so that the terminal is restored on the way out.
+				panic(r)
+			case <-quit:
+				return nil
+			case choice = <-selection:
+				return nil
+			case <-abort:
+				return ErrAborted
+			case <-tstp:
+				err := suspend()
+				if err != nil {
+					return err
+				}
+			}
+		}
 	})
 	if err != nil {
 		return err
 	}
-	if notePath == "" {
+	choice.Notes = uniqueNotes(choice.Notes)
+	switch {
+	case len(choice.Notes) == 0:
 		return nil
+	case choice.How == OPEN_PAGER:
+		return openPager(choice.Notes)
+	case choice.How == OPEN_PRINT_PATH:
+		return printPaths(choice.Notes)
+	case choice.How == OPEN_COPY_PATH:
+		return copyPaths(choice.Notes)
+	case choice.How == OPEN_COPY_CONTENTS:
+		return copyContents(choice.Notes)
 	}
 	editor := os.Getenv("VISUAL")
 	if editor == "" {
@@ -113,15 +289,77 @@ func Run() error {
 	if err != nil {
 		return err
 	}
-	err = syscall.Exec(editorPath, []string{editor, notePath}, os.Environ())
+	args := []string{editor}
+	if len(choice.Notes) > 1 {
+		if argument := multipleArgument(config, editor); argument != "" {
+			args = append(args, argument)
+		}
+	} else if choice.Notes[0].Line > 0 {
+		// Only a single note is opened at a line, as editors apply the
+		// line to the first file.
+		if format := lineArgument(config, editor); format != "" {
+			args = append(args, fmt.Sprintf(format, choice.Notes[0].Line))
+		}
+	}
+	for _, s := range choice.Notes {
+		args = append(args, s.Path)
+	}
+	err = syscall.Exec(editorPath, args, os.Environ())
 	if err != nil {
 		return err
 	}
 	return nil // NEVER RUN
 }
 
+// uniqueNotes returns the notes with each path once, at the first of its
+// lines, as several marked lines may be of the same note.
+func uniqueNotes(notes []Selection) []Selection {
+	unique := []Selection{}
+	seen := map[string]bool{}
+	for _, s := range notes {
+		if !seen[s.Path] {
+			seen[s.Path] = true
+			unique = append(unique, s)
+		}
+	}
+	return unique
+}
+
+// lineArgument returns the format of the argument opening a note at a line
+// in the editor, or "" if the editor is not known to support one.
+func lineArgument(config *Config, editor string) string {
+	if config.EditorLineArgument != "" {
+		return config.EditorLineArgument
+	}
+	base := path.Base(editor)
+	for _, e := range LINE_ARGUMENT_EDITORS {
+		if base == e {
+			return "+%d"
+		}
+	}
+	return ""
+}
+
+// multipleArgument returns the argument opening several notes at once in the
+// editor, or "" if the editor is not known to need one.
+func multipleArgument(config *Config, editor string) string {
+	if config.EditorMultipleArgument != "" {
+		return config.EditorMultipleArgument
+	}
+	base := path.Base(editor)
+	for _, e := range TAB_ARGUMENT_EDITORS {
+		if base == e {
+			return "-p"
+		}
+	}
+	return ""
+}
+
 func main() {
 	err := Run()
+	if err == ErrAborted {
+		os.Exit(ABORT_EXIT_STATUS)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
 		os.Exit(1)
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Actions of the menu for the selected note.
+const (
+	MENU_RENAME = iota
+	MENU_DELETE
+	MENU_DUPLICATE
+	MENU_MOVE
+	MENU_COPY_PATH
+	// Creating a note, which is not in the menu but prompts for a title or
+	// confirmation like the actions in it.
+	MENU_CREATE
+)
+
+// MENU_ITEMS are the labels of the actions of the menu, with the keys
+// choosing them.
+var MENU_ITEMS = []struct {
+	Key   rune
+	Label string
+}{
+	MENU_RENAME:    {'r', "Rename"},
+	MENU_DELETE:    {'d', "Delete"},
+	MENU_DUPLICATE: {'u', "Duplicate"},
+	MENU_MOVE:      {'m', "Move to folder"},
+	MENU_COPY_PATH: {'y', "Copy path"},
+}
+
+// States of the menu.
+const (
+	MENU_CLOSED = iota
+	// Choosing an action.
+	MENU_LIST
+	// Entering the title or folder an action needs.
+	MENU_PROMPT
+	// Confirming an action that cannot be undone.
+	MENU_CONFIRM
+)
+
+// MenuView is what the menu shows in place of the query and results.
+type MenuView struct {
+	Open bool
+	// Shown in place of the query.
+	Header string
+	// The position of the cursor in the header, in runes.
+	Cursor int
+	// Shown in place of the results.
+	Items     []string
+	Selection int
+}
+
+type MenuManagerOptions struct {
+	Writer         io.Writer
+	Search         *SearchClient
+	NotesDirectory string
+}
+
+// MenuManager holds the state of the menu of actions on the selected note.
+// The actions are run by its client, so it has no Start.
+type MenuManager struct {
+	Options MenuManagerOptions
+	state   int
+	// The title of the note the menu is for, or of the note to create.
+	title     string
+	selection int
+	// The action being prompted for or confirmed.
+	item   int
+	prompt *LineEditor
+	// The error of the last action, shown until the menu is closed.
+	message string
+	// The title of an existing note similar to the one to create.
+	similar string
+	trigger *Trigger
+	mutex   *sync.RWMutex
+}
+
+func NewMenuManager(options MenuManagerOptions) *MenuManager {
+	return &MenuManager{
+		options,
+		MENU_CLOSED,
+		"", 0,
+		0, &LineEditor{},
+		"", "",
+		NewTrigger(),
+		&sync.RWMutex{}}
+}
+
+func (mm *MenuManager) Client() *MenuClient {
+	return &MenuClient{mm}
+}
+
+func (mm *MenuManager) notify() {
+	Logger.Print("MenuManager Notify")
+	mm.trigger.Notify()
+}
+
+// run runs an action on the note, with the text entered for it, and refreshes
+// the results.
+func (mm *MenuManager) run(item int, title, text string) error {
+	Logger.Printf("Running %s on %s", MENU_ITEMS[item].Label, title)
+	notesDirectory := mm.Options.NotesDirectory
+	switch item {
+	case MENU_RENAME:
+		return renameNote(notesDirectory, title, text)
+	case MENU_DELETE:
+		return deleteNote(notesDirectory, title)
+	case MENU_DUPLICATE:
+		return duplicateNote(notesDirectory, title, text)
+	case MENU_MOVE:
+		return moveNote(notesDirectory, title, text)
+	case MENU_COPY_PATH:
+		ansi := ANSI{mm.Options.Writer}
+		return ansi.OSC52(notePath(notesDirectory, title))
+	}
+	return nil
+}
+
+type MenuClient struct {
+	mm *MenuManager
+}
+
+// Open opens the menu for a note.
+func (mc *MenuClient) Open(title string) {
+	mc.mm.mutex.Lock()
+	mc.mm.state = MENU_LIST
+	mc.mm.title = title
+	mc.mm.selection = 0
+	mc.mm.message = ""
+	mc.mm.mutex.Unlock()
+	mc.mm.notify()
+}
+
+func (mc *MenuClient) Close() {
+	mc.mm.mutex.Lock()
+	mc.mm.state = MENU_CLOSED
+	mc.mm.mutex.Unlock()
+	mc.mm.notify()
+}
+
+func (mc *MenuClient) IsOpen() bool {
+	mc.mm.mutex.RLock()
+	open := mc.mm.state != MENU_CLOSED
+	mc.mm.mutex.RUnlock()
+	return open
+}
+
+func (mc *MenuClient) View() MenuView {
+	mc.mm.mutex.RLock()
+	defer mc.mm.mutex.RUnlock()
+	mm := mc.mm
+	view := MenuView{Open: mm.state != MENU_CLOSED, Selection: -1}
+	switch mm.state {
+	case MENU_LIST:
+		view.Header = fmt.Sprintf("Actions for %s", mm.title)
+		if mm.message != "" {
+			view.Header = fmt.Sprintf("%s: %s", mm.title, mm.message)
+		}
+		view.Items = make([]string, len(MENU_ITEMS))
+		for i, item := range MENU_ITEMS {
+			view.Items[i] = fmt.Sprintf("%c  %s", item.Key, item.Label)
+		}
+		view.Selection = mm.selection
+	case MENU_PROMPT:
+		var label string
+		switch mm.item {
+		case MENU_RENAME:
+			label = fmt.Sprintf("Rename %s to: ", mm.title)
+		case MENU_DUPLICATE:
+			label = fmt.Sprintf("Duplicate %s as: ", mm.title)
+		case MENU_MOVE:
+			label = fmt.Sprintf("Move %s to folder: ", mm.title)
+		case MENU_CREATE:
+			label = "Title of new note: "
+		}
+		if mm.message != "" {
+			label = fmt.Sprintf("[%s] %s", mm.message, label)
+		}
+		view.Header = label + mm.prompt.String()
+		view.Cursor = len([]rune(label)) + mm.prompt.Cursor()
+		return view
+	case MENU_CONFIRM:
+		view.Header = fmt.Sprintf("Delete %s? (y/n) ", mm.title)
+		if mm.item == MENU_CREATE {
+			view.Header = fmt.Sprintf(
+				"%s is much like existing note %s, create it anyway? (y/n) ",
+				mm.title, mm.similar)
+		}
+	}
+	view.Cursor = len([]rune(view.Header))
+	return view
+}
+
+func (mc *MenuClient) SelectPrevious() {
+	mc.mm.mutex.Lock()
+	if mc.mm.state == MENU_LIST && mc.mm.selection > 0 {
+		mc.mm.selection--
+	}
+	mc.mm.mutex.Unlock()
+	mc.mm.notify()
+}
+
+func (mc *MenuClient) SelectNext() {
+	mc.mm.mutex.Lock()
+	if mc.mm.state == MENU_LIST && mc.mm.selection < len(MENU_ITEMS)-1 {
+		mc.mm.selection++
+	}
+	mc.mm.mutex.Unlock()
+	mc.mm.notify()
+}
+
+// choose prompts for what an action needs, asks to confirm it, or runs it
+// right away.
+func (mc *MenuClient) choose(item int) {
+	mc.mm.mutex.Lock()
+	title := mc.mm.title
+	mc.mm.item = item
+	switch item {
+	case MENU_RENAME:
+		mc.mm.state = MENU_PROMPT
+		mc.mm.prompt.Set(title)
+	case MENU_DUPLICATE:
+		mc.mm.state = MENU_PROMPT
+		mc.mm.prompt.Set(title + " copy")
+	case MENU_MOVE:
+		mc.mm.state = MENU_PROMPT
+		mc.mm.prompt.Set("")
+	case MENU_DELETE:
+		mc.mm.state = MENU_CONFIRM
+	default:
+		mc.mm.mutex.Unlock()
+		mc.runChosen("")
+		return
+	}
+	mc.mm.mutex.Unlock()
+	mc.mm.notify()
+}
+
+// Create creates a note, prompting for its title if it has none or an invalid
+// one, and asking to confirm if a note with a similar title exists.
+func (mc *MenuClient) Create(title string) {
+	mm := mc.mm
+	var message string
+	similar := ""
+	err := checkTitle(title)
+	if err == nil {
+		similar, err = similarNote(mm.Options.NotesDirectory, title)
+	}
+	if err != nil && title != "" {
+		message = err.Error()
+	}
+	mm.mutex.Lock()
+	mm.item = MENU_CREATE
+	mm.message = message
+	switch {
+	case err != nil:
+		mm.state = MENU_PROMPT
+		mm.title = ""
+		mm.prompt.Set(title)
+	case similar != "":
+		mm.state = MENU_CONFIRM
+		mm.title = title
+		mm.similar = similar
+	default:
+		mm.state = MENU_CLOSED
+	}
+	mm.mutex.Unlock()
+	mm.notify()
+	if err == nil && similar == "" {
+		mm.Options.Search.Create(title)
+	}
+}
+
+// runChosen runs the chosen action, closing the menu if it succeeds or
+// showing the error otherwise.
+func (mc *MenuClient) runChosen(text string) {
+	mc.mm.mutex.RLock()
+	state, item, title := mc.mm.state, mc.mm.item, mc.mm.title
+	mc.mm.mutex.RUnlock()
+	if item == MENU_CREATE {
+		if state == MENU_PROMPT {
+			mc.Create(text)
+			return
+		}
+		mc.Close()
+		mc.mm.Options.Search.Create(title)
+		return
+	}
+	err := mc.mm.run(item, title, text)
+	mc.mm.mutex.Lock()
+	if err != nil {
+		Logger.Print("Error running action: ", err)
+		mc.mm.state = MENU_LIST
+		mc.mm.message = err.Error()
+	} else {
+		mc.mm.state = MENU_CLOSED
+	}
+	mc.mm.mutex.Unlock()
+	mc.mm.notify()
+	if err == nil && item != MENU_COPY_PATH {
+		mc.mm.Options.Search.Refresh()
+	}
+}
+
+// Accept chooses the selected action, or runs the action being prompted for.
+// A confirmation is answered only by y, so accepting one cancels it.
+func (mc *MenuClient) Accept() {
+	mc.mm.mutex.RLock()
+	state, selection := mc.mm.state, mc.mm.selection
+	text := mc.mm.prompt.String()
+	mc.mm.mutex.RUnlock()
+	switch state {
+	case MENU_LIST:
+		mc.choose(selection)
+	case MENU_PROMPT:
+		mc.runChosen(text)
+	case MENU_CONFIRM:
+		mc.Close()
+	}
+}
+
+// HandleRune chooses the action of a key, answers a confirmation, or types in
+// the prompt.
+func (mc *MenuClient) HandleRune(c rune) {
+	mc.mm.mutex.RLock()
+	state := mc.mm.state
+	mc.mm.mutex.RUnlock()
+	switch state {
+	case MENU_LIST:
+		for i, item := range MENU_ITEMS {
+			if item.Key == c {
+				mc.choose(i)
+				return
+			}
+		}
+	case MENU_PROMPT:
+		mc.Edit(func(le *LineEditor) {
+			le.Insert(c)
+		})
+	case MENU_CONFIRM:
+		if c == 'y' || c == 'Y' {
+			mc.runChosen("")
+			return
+		}
+		mc.Close()
+	}
+}
+
+// Edit applies f to the prompt.
+func (mc *MenuClient) Edit(f func(le *LineEditor)) {
+	mc.mm.mutex.Lock()
+	if mc.mm.state == MENU_PROMPT {
+		f(mc.mm.prompt)
+	}
+	mc.mm.mutex.Unlock()
+	mc.mm.notify()
+}
+
+func (mc *MenuClient) Subscribe() Subscription {
+	return mc.mm.trigger.Subscribe()
+}
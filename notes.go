@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// notePath returns the path of the note with a title.
+func notePath(notesDirectory, title string) string {
+	return path.Join(notesDirectory, title+".txt")
+}
+
+// checkTitle returns an error if a note cannot be given a title.
+func checkTitle(title string) error {
+	if strings.TrimSpace(title) == "" {
+		return fmt.Errorf("empty title")
+	}
+	if strings.Contains(title, "/") {
+		return fmt.Errorf("title %q contains a slash", title)
+	}
+	return nil
+}
+
+// checkMissing returns an error if a file exists, so that it is not
+// overwritten.
+func checkMissing(p string) error {
+	_, err := os.Stat(p)
+	if err == nil {
+		return fmt.Errorf("%s already exists", p)
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func renameNote(notesDirectory, title, newTitle string) error {
+	err := checkTitle(newTitle)
+	if err != nil {
+		return err
+	}
+	newPath := notePath(notesDirectory, newTitle)
+	err = checkMissing(newPath)
+	if err != nil {
+		return err
+	}
+	return os.Rename(notePath(notesDirectory, title), newPath)
+}
+
+func deleteNote(notesDirectory, title string) error {
+	return os.Remove(notePath(notesDirectory, title))
+}
+
+func duplicateNote(notesDirectory, title, newTitle string) error {
+	err := checkTitle(newTitle)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(notePath(notesDirectory, title))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(
+		notePath(notesDirectory, newTitle),
+		os.O_WRONLY|os.O_CREATE|os.O_EXCL,
+		0666)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// moveNote moves a note to a folder, relative to the notes directory unless
+// absolute. Notes in nested folders are not searched, so this archives them.
+func moveNote(notesDirectory, title, folder string) error {
+	if strings.TrimSpace(folder) == "" {
+		return fmt.Errorf("empty folder")
+	}
+	if !path.IsAbs(folder) {
+		folder = path.Join(notesDirectory, folder)
+	}
+	if path.Clean(folder) == path.Clean(notesDirectory) {
+		return fmt.Errorf("%s is the notes directory", folder)
+	}
+	err := os.MkdirAll(folder, 0777)
+	if err != nil {
+		return err
+	}
+	newPath := notePath(folder, title)
+	err = checkMissing(newPath)
+	if err != nil {
+		return err
+	}
+	return os.Rename(notePath(notesDirectory, title), newPath)
+}
+
+// normalizeTitle returns a title as compared to others to find similar ones.
+func normalizeTitle(title string) []rune {
+	return []rune(strings.Join(strings.Fields(strings.ToLower(title)), " "))
+}
+
+// similarNote returns the title of a note whose title is much like the given
+// one, differing only by case, spacing or a few typos, or "" if there is none
+// or a note has exactly the given title.
+func similarNote(notesDirectory, title string) (string, error) {
+	titles, err := listNotes(notesDirectory)
+	if err != nil {
+		return "", err
+	}
+	normalized := normalizeTitle(title)
+	k := maxDistance(normalized)
+	similar := ""
+	for _, t := range titles {
+		if t == title {
+			return "", nil
+		}
+		if similar == "" &&
+			boundedDistance(normalizeTitle(t), normalized, k) <= k {
+			similar = t
+		}
+	}
+	return similar, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+const DEFAULT_PAGER = "less"
+
+// openPager replaces the process with the pager, showing the notes without
+// risking changes.
+func openPager(notes []Selection) error {
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = DEFAULT_PAGER
+	}
+	pagerPath, err := exec.LookPath(pager)
+	if err != nil {
+		return err
+	}
+	args := []string{pager}
+	for _, s := range notes {
+		args = append(args, s.Path)
+	}
+	err = syscall.Exec(pagerPath, args, os.Environ())
+	if err != nil {
+		return err
+	}
+	return nil // NEVER RUN
+}
+
+// printPaths prints the paths of the notes, one per line, for use by other
+// commands.
+func printPaths(notes []Selection) error {
+	for _, s := range notes {
+		_, err := fmt.Println(s.Path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyPaths copies the paths of the notes to the clipboard, one per line.
+func copyPaths(notes []Selection) error {
+	paths := make([]string, len(notes))
+	for i, s := range notes {
+		paths[i] = s.Path
+	}
+	ansi := ANSI{os.Stdout}
+	return ansi.OSC52(strings.Join(paths, "\n"))
+}
+
+// copyContents copies the contents of the notes to the clipboard, separated
+// by blank lines.
+func copyContents(notes []Selection) error {
+	contents := make([]string, len(notes))
+	for i, s := range notes {
+		b, err := ioutil.ReadFile(s.Path)
+		if err != nil {
+			return err
+		}
+		contents[i] = strings.TrimRight(string(b), "\n")
+	}
+	ansi := ANSI{os.Stdout}
+	return ansi.OSC52(strings.Join(contents, "\n\n"))
+}
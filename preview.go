@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Lines shown above the first line matching the query when a note is
+// previewed.
+const PREVIEW_CONTEXT_LINES = 2
+
+// Places of the preview pane.
+const (
+	PREVIEW_RIGHT = iota
+	PREVIEW_BOTTOM
+)
+
+// PreviewLine is a line of the previewed note.
+type PreviewLine struct {
+	Text string
+	// The byte ranges of the text matching the query.
+	Matches [][]int
+}
+
+// PreviewView is what the preview pane shows.
+type PreviewView struct {
+	Open bool
+	// The lines of the note from the first one shown.
+	Lines []PreviewLine
+}
+
+type PreviewManagerOptions struct {
+	Search         *SearchClient
+	NotesDirectory string
+}
+
+// PreviewManager loads the note of the selected result while the preview
+// pane is open.
+type PreviewManager struct {
+	Options PreviewManagerOptions
+	open    bool
+	// What the loaded lines are for, so that a note is loaded again only
+	// when another one is selected or the query changes.
+	loaded string
+	lines  []PreviewLine
+	// The index of the first line shown.
+	offset int
+	// Notified when the pane is opened.
+	loadTrigger *Trigger
+	trigger     *Trigger
+	mutex       *sync.RWMutex
+}
+
+func NewPreviewManager(options PreviewManagerOptions) *PreviewManager {
+	return &PreviewManager{
+		options,
+		false,
+		"", nil,
+		0,
+		NewTrigger(),
+		NewTrigger(),
+		&sync.RWMutex{}}
+}
+
+func (pm *PreviewManager) Client() *PreviewClient {
+	return &PreviewClient{pm}
+}
+
+func (pm *PreviewManager) notify() {
+	Logger.Print("PreviewManager Notify")
+	pm.trigger.Notify()
+}
+
+// previewText replaces tabs and other control characters, which would move
+// the cursor, with spaces.
+func previewText(s string) string {
+	s = strings.ReplaceAll(s, "\t", "    ")
+	return strings.Map(func(c rune) rune {
+		if c < 0x20 || c == 0x7F {
+			return ' '
+		}
+		return c
+	}, s)
+}
+
+// loadNote reads the lines of a note, finding the query in them, and returns
+// the index of the line to show first.
+func loadNote(path, query string, line int) ([]PreviewLine, int, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	var pattern *regexp.Regexp
+	if query != "" {
+		pattern = regexp.MustCompile("(?i)" + regexp.QuoteMeta(query))
+	}
+	texts := strings.Split(strings.TrimRight(string(b), "\n"), "\n")
+	lines := make([]PreviewLine, len(texts))
+	// The first hit is the matching line of the result, if it has one.
+	hit := line - 1
+	for i, text := range texts {
+		lines[i].Text = previewText(text)
+		if pattern == nil {
+			continue
+		}
+		lines[i].Matches = pattern.FindAllStringIndex(lines[i].Text, -1)
+		if hit < 0 && lines[i].Matches != nil {
+			hit = i
+		}
+	}
+	offset := hit - PREVIEW_CONTEXT_LINES
+	if offset > len(lines)-1 {
+		offset = len(lines) - 1
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return lines, offset, nil
+}
+
+// load loads the note of the selected result, unless it is already loaded.
+func (pm *PreviewManager) load() {
+	search := pm.Options.Search
+	selection, results := search.Results()
+	query := search.Query()
+	if search.Related() != "" {
+		query = ""
+	}
+	var result Result
+	if selection >= 0 && selection < len(results) &&
+		!results[selection].Create {
+		result = results[selection]
+	}
+	loaded := fmt.Sprintf("%s:%d:%s", result.Title, result.Line, query)
+
+	pm.mutex.RLock()
+	unchanged := pm.loaded == loaded
+	pm.mutex.RUnlock()
+	if unchanged {
+		return
+	}
+
+	var (
+		lines  []PreviewLine
+		offset int
+		err    error
+	)
+	if result.Title != "" {
+		lines, offset, err = loadNote(
+			notePath(pm.Options.NotesDirectory, result.Title),
+			query, result.Line)
+		if err != nil {
+			Logger.Print("Error loading preview: ", err)
+			lines = []PreviewLine{{Text: err.Error()}}
+		}
+	}
+	pm.mutex.Lock()
+	pm.loaded = loaded
+	pm.lines = lines
+	pm.offset = offset
+	pm.mutex.Unlock()
+	pm.notify()
+}
+
+func (pm *PreviewManager) Start() error {
+	if pm.Options.Search == nil {
+		return fmt.Errorf("no Search")
+	}
+	if pm.Options.NotesDirectory == "" {
+		return fmt.Errorf("no NotesDirectory")
+	}
+	subscription := NewAnySubscription(
+		pm.Options.Search.Subscribe(),
+		pm.loadTrigger.Subscribe())
+	Logger.Print("Starting PreviewManager")
+	for {
+		subscription.Wait()
+		pm.mutex.RLock()
+		open := pm.open
+		pm.mutex.RUnlock()
+		if open {
+			pm.load()
+		}
+	}
+}
+
+type PreviewClient struct {
+	pm *PreviewManager
+}
+
+// Toggle opens the preview pane, or closes it if it is open.
+func (pc *PreviewClient) Toggle() {
+	pc.pm.mutex.Lock()
+	pc.pm.open = !pc.pm.open
+	// Load the note again once opened, as it may have changed since.
+	pc.pm.loaded = ""
+	pc.pm.lines = nil
+	pc.pm.mutex.Unlock()
+	pc.pm.notify()
+	pc.pm.loadTrigger.Notify()
+}
+
+// Scroll moves the lines shown by delta, keeping at least the last line
+// shown.
+func (pc *PreviewClient) Scroll(delta int) {
+	pc.pm.mutex.Lock()
+	pc.pm.offset += delta
+	if pc.pm.offset > len(pc.pm.lines)-1 {
+		pc.pm.offset = len(pc.pm.lines) - 1
+	}
+	if pc.pm.offset < 0 {
+		pc.pm.offset = 0
+	}
+	pc.pm.mutex.Unlock()
+	pc.pm.notify()
+}
+
+func (pc *PreviewClient) View() PreviewView {
+	pc.pm.mutex.RLock()
+	defer pc.pm.mutex.RUnlock()
+	view := PreviewView{Open: pc.pm.open}
+	if pc.pm.offset < len(pc.pm.lines) {
+		view.Lines = pc.pm.lines[pc.pm.offset:]
+	}
+	return view
+}
+
+func (pc *PreviewClient) Subscribe() Subscription {
+	return pc.pm.trigger.Subscribe()
+}
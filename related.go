@@ -0,0 +1,50 @@
+package main
+
+import (
+	"sort"
+)
+
+const MAX_RELATED_NOTES = 20
+
+type similarity struct {
+	title string
+	score float64
+}
+
+// Related returns the titles of at most n notes most similar to the given
+// note, by cosine similarity of their TF-IDF vectors.
+func (idx *Index) Related(title string, n int) []string {
+	v := idx.vectors[title]
+	if v.norm == 0 {
+		return []string{}
+	}
+	similarities := []similarity{}
+	for other, otherV := range idx.vectors {
+		if other == title || otherV.norm == 0 {
+			continue
+		}
+		var dot float64
+		for term, weight := range v.weights {
+			dot += weight * otherV.weights[term]
+		}
+		if dot == 0 {
+			continue
+		}
+		similarities = append(
+			similarities, similarity{other, dot / (v.norm * otherV.norm)})
+	}
+	sort.Slice(similarities, func(i, j int) bool {
+		if similarities[i].score != similarities[j].score {
+			return similarities[i].score > similarities[j].score
+		}
+		return similarities[i].title < similarities[j].title
+	})
+	if len(similarities) > n {
+		similarities = similarities[:n]
+	}
+	titles := make([]string, len(similarities))
+	for i := range titles {
+		titles[i] = similarities[i].title
+	}
+	return titles
+}
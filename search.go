@@ -2,49 +2,122 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path"
-	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
+// Result is a note found by a search.
+type Result struct {
+	Title string
+	// The first line of the note matching the query, or 0 if only the title
+	// matched.
+	Line int
+	// The text of the matching line, set when each matching line is a
+	// separate result.
+	Snippet string
+	// Whether the result is the row creating a note titled by the query.
+	Create bool
+}
+
+// Label returns the text displayed for the result.
+func (r Result) Label() string {
+	if r.Create {
+		return fmt.Sprintf("+ Create '%s'", r.Title)
+	}
+	if r.Snippet == "" {
+		return r.Title
+	}
+	return fmt.Sprintf("%s:%d: %s", r.Title, r.Line, r.Snippet)
+}
+
+// key identifies the result across searches, as the first matching line of a
+// note changes with the query unless each line is a separate result.
+func (r Result) key() string {
+	if r.Snippet == "" {
+		return r.Title
+	}
+	return fmt.Sprintf("%s:%d", r.Title, r.Line)
+}
+
 type result struct {
-	title string
-	count int
+	Result
+	count       int
+	approximate bool
 }
 
 type Results struct {
-	results []result
-	m       map[string]*result
+	// Whether every matching line is a separate result.
+	expandLines bool
+	results     []result
+	m           map[string]int
+	// The titles of the notes found, which differ from the keys of results
+	// when every matching line is a separate result.
+	titles map[string]bool
 }
 
-func NewResults() *Results {
-	return &Results{nil, map[string]*result{}}
+func NewResults(expandLines bool) *Results {
+	return &Results{expandLines, nil, map[string]int{}, map[string]bool{}}
 }
 
-func (r *Results) Add(title string) {
-	var (
-		res *result
-		ok  bool
-	)
-	res, ok = r.m[title]
+func (r *Results) get(key string, res Result) *result {
+	i, ok := r.m[key]
 	if !ok {
-		r.results = append(r.results, result{title, 0})
-		res = &r.results[len(r.results)-1]
-		r.m[title] = res
+		r.results = append(r.results, result{res, 0, false})
+		i = len(r.results) - 1
+		r.m[key] = i
+		r.titles[res.Title] = true
+	}
+	return &r.results[i]
+}
+
+func (r *Results) Add(title string) {
+	r.get(title, Result{Title: title}).count++
+}
+
+// AddLine adds an occurrence of the query on a line of a note.
+func (r *Results) AddLine(title string, line int, snippet string) {
+	if r.expandLines {
+		key := fmt.Sprintf("%s:%d", title, line)
+		r.get(key, Result{title, line, snippet, false}).count++
+		return
+	}
+	res := r.get(title, Result{Title: title})
+	if res.Line == 0 || line < res.Line {
+		res.Line = line
 	}
 	res.count++
 }
 
+// AddApproximate adds a note found by approximate matching, unless it was
+// already found by exact matching.
+func (r *Results) AddApproximate(title string, count int) {
+	if r.titles[title] {
+		return
+	}
+	r.results = append(r.results, result{Result{Title: title}, count, true})
+	r.m[title] = len(r.results) - 1
+	r.titles[title] = true
+}
+
 func (r *Results) Len() int {
 	return len(r.results)
 }
 
 func (r *Results) Less(i, j int) bool {
+	// Approximate matches rank below exact ones.
+	if r.results[i].approximate != r.results[j].approximate {
+		return r.results[j].approximate
+	}
 	return r.results[i].count < r.results[j].count
 }
 
@@ -52,25 +125,74 @@ func (r *Results) Swap(i, j int) {
 	r.results[i], r.results[j] = r.results[j], r.results[i]
 }
 
-func (r *Results) Sorted() []string {
-	sort.Sort(r)
-	titles := make([]string, len(r.results))
-	for i := range titles {
-		titles[i] = r.results[i].title
+func (r *Results) Sorted() []Result {
+	// Keep the lines of a note in order.
+	sort.Stable(r)
+	results := make([]Result, len(r.results))
+	for i := range results {
+		results[i] = r.results[i].Result
 	}
-	return titles
+	return results
+}
+
+// Selection is a note chosen by the user.
+type Selection struct {
+	Path string
+	// The line to open the note at, or 0.
+	Line int
+}
+
+// OpenMode is a way of opening the chosen notes.
+type OpenMode int
+
+const (
+	OPEN_EDITOR OpenMode = iota
+	OPEN_PAGER
+	OPEN_PRINT_PATH
+	OPEN_COPY_PATH
+	OPEN_COPY_CONTENTS
+)
+
+// Choice is the notes chosen by the user and how to open them.
+type Choice struct {
+	// The notes, or none if the user chose to exit.
+	Notes []Selection
+	How   OpenMode
+}
+
+// SearchStatus describes the last search, and is published alongside its
+// results.
+type SearchStatus struct {
+	// Whether a search is running, whose results are not shown yet.
+	Searching bool
+	// How long the last search took.
+	Duration time.Duration
+	// How the results were found, such as "related" for the notes related to
+	// another or "fuzzy" for notes found by approximate matching.
+	Modes []string
+	// The error of the last search, which left the results as they were.
+	Error string
 }
 
 type SearchManagerOptions struct {
-	Selection      chan<- string
+	Selection      chan<- Choice
 	NotesDirectory string
+	// Whether every matching line is a separate result.
+	ExpandLines bool
 }
 
 type SearchManager struct {
-	Options      SearchManagerOptions
-	query        []rune
-	results      []string
-	selection    int
+	Options   SearchManagerOptions
+	query     *LineEditor
+	results   []Result
+	selection int
+	// Results marked to be opened together, in the order they were marked.
+	marked  []Result
+	related string
+	index   *Index
+	status  SearchStatus
+	// When the running search started.
+	started      time.Time
 	queryTrigger *Trigger
 	trigger      *Trigger
 	mutex        *sync.RWMutex
@@ -78,7 +200,13 @@ type SearchManager struct {
 
 func NewSearchManager(options SearchManagerOptions) *SearchManager {
 	return &SearchManager{
-		options, nil, nil, -1, NewTrigger(), NewTrigger(), &sync.RWMutex{}}
+		options,
+		&LineEditor{}, nil, -1,
+		nil,
+		"", nil,
+		SearchStatus{}, time.Time{},
+		NewTrigger(), NewTrigger(),
+		&sync.RWMutex{}}
 }
 
 func (sm *SearchManager) Client() *SearchClient {
@@ -95,35 +223,7 @@ func (sm *SearchManager) notifyQuery() {
 }
 
 func (sm *SearchManager) searchTitles(query string, results *Results) error {
-	var (
-		titles = []string{}
-		err    error
-	)
-	walkFunc := func(p string, info os.FileInfo, err error) error {
-		if p == sm.Options.NotesDirectory {
-			if err != nil {
-				return err
-			}
-			return nil
-		}
-		if err != nil {
-			Logger.Print("Error while walking directory: ", err)
-			return nil
-		}
-		if info.IsDir() {
-			Logger.Print("Encountered nested directory")
-			return filepath.SkipDir
-		}
-		base := path.Base(p)
-		title := strings.TrimSuffix(base, ".txt")
-		if title == base {
-			Logger.Printf("Encountered malformed filename: %s", base)
-			return nil
-		}
-		titles = append(titles, title)
-		return nil
-	}
-	err = filepath.Walk(sm.Options.NotesDirectory, walkFunc)
+	titles, err := listNotes(sm.Options.NotesDirectory)
 	if err != nil {
 		return err
 	}
@@ -140,7 +240,8 @@ func (sm *SearchManager) searchTitles(query string, results *Results) error {
 	if err != nil {
 		return err
 	}
-	cmd.Stderr = os.Stderr
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
 
 	err = cmd.Start()
 	if err != nil {
@@ -158,65 +259,175 @@ func (sm *SearchManager) searchTitles(query string, results *Results) error {
 		return err
 	}
 
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
+	err = readLines(stdout, func(line string) {
 		// TODO: handle multiple occurrences in title
-		results.Add(strings.TrimSuffix(scanner.Text(), "\n"))
-	}
-
-	err = cmd.Wait()
-	if exitErr, ok := err.(*exec.ExitError); ok {
-		if exitErr.ExitCode() != 1 {
-			return err
-		}
-	} else if err != nil {
+		results.Add(line)
+	})
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
 		return err
 	}
 
-	return nil
+	return grepError(cmd.Wait(), stderr.String())
 }
 
 func (sm *SearchManager) searchContents(query string, results *Results) error {
 	var err error
-	cmd := exec.Command("grep", "-i", "-o", "-R", query, sm.Options.NotesDirectory)
+	cmd := exec.Command(
+		"grep", "-i", "-n", "-R", query, sm.Options.NotesDirectory)
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return err
 	}
-	cmd.Stderr = os.Stderr
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
 
 	err = cmd.Start()
 	if err != nil {
 		return err
 	}
 
-	scanner := bufio.NewScanner(stdout)
-	var title string
-	for scanner.Scan() {
-		line := strings.TrimSuffix(scanner.Text(), "\n")
-		if strings.Index(line, sm.Options.NotesDirectory) == 0 {
-			title = strings.TrimSuffix(
-				path.Base(line[0:strings.Index(line, ":")]), ".txt")
+	// Lines are formatted as <directory>/<title>.txt:<line>:<text>.
+	prefix := path.Clean(sm.Options.NotesDirectory) + "/"
+	err = readLines(stdout, func(line string) {
+		if !strings.HasPrefix(line, prefix) {
+			return
+		}
+		line = line[len(prefix):]
+		i := strings.Index(line, ".txt:")
+		if i == -1 {
+			return
+		}
+		title := line[:i]
+		if strings.Contains(title, "/") {
+			return
+		}
+		fields := strings.SplitN(line[i+len(".txt:"):], ":", 2)
+		if len(fields) != 2 {
+			return
+		}
+		n, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return
 		}
-		results.Add(title)
+		results.AddLine(title, n, strings.TrimSpace(fields[1]))
+	})
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
 	}
 
-	err = cmd.Wait()
-	if exitErr, ok := err.(*exec.ExitError); ok {
-		if exitErr.ExitCode() != 1 {
+	return grepError(cmd.Wait(), stderr.String())
+}
+
+// Lines of the output of grep are cut to this length, as a line of a note,
+// such as pasted data, may be of any length.
+const MAX_LINE_LENGTH = 64 * 1024
+
+// readLines calls f with each line read from r, cut to MAX_LINE_LENGTH, until
+// r ends. The rest of a line cut is read and discarded, so that the writer of
+// r is never left blocked.
+func readLines(r io.Reader, f func(line string)) error {
+	reader := bufio.NewReaderSize(r, MAX_LINE_LENGTH)
+	for {
+		b, more, err := reader.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
 			return err
 		}
-	} else if err != nil {
-		return err
+		line := string(b)
+		for more {
+			_, more, err = reader.ReadLine()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+		f(line)
 	}
+}
 
+// grepError returns the error of grep, with what it printed, unless it only
+// found no matches.
+func grepError(err error, stderr string) error {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if exitErr.ExitCode() == 1 {
+			return nil
+		}
+		if stderr = strings.TrimSpace(stderr); stderr != "" {
+			return errors.New(stderr)
+		}
+	}
+	return err
+}
+
+// loadIndex returns the index of the notes, building it unless it was built
+// since the notes last changed.
+func (sm *SearchManager) loadIndex() (*Index, error) {
+	sm.mutex.RLock()
+	index := sm.index
+	sm.mutex.RUnlock()
+	if index != nil && index.Current(sm.Options.NotesDirectory) {
+		return index, nil
+	}
+	index, err := BuildIndex(sm.Options.NotesDirectory)
+	if err != nil {
+		return nil, err
+	}
+	sm.mutex.Lock()
+	sm.index = index
+	sm.mutex.Unlock()
+	return index, nil
+}
+
+func (sm *SearchManager) searchRelated(title string) ([]Result, error) {
+	index, err := sm.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	titles := index.Related(title, MAX_RELATED_NOTES)
+	results := make([]Result, len(titles))
+	for i, title := range titles {
+		results[i] = Result{Title: title}
+	}
+	return results, nil
+}
+
+func (sm *SearchManager) searchApproximate(
+	query string, results *Results) error {
+	index, err := sm.loadIndex()
+	if err != nil {
+		return err
+	}
+	for title, count := range index.Approximate(query) {
+		results.AddApproximate(title, count)
+	}
 	return nil
 }
 
 func (sm *SearchManager) search() error {
+	sm.mutex.RLock()
+	related := sm.related
+	query := sm.query.String()
+	sm.mutex.RUnlock()
+	if related != "" {
+		Logger.Print("Searching notes related to ", related)
+		results, err := sm.searchRelated(related)
+		if err != nil {
+			return err
+		}
+		sm.setResults(results, []string{"related"})
+		return nil
+	}
+
 	var (
-		query   = string(sm.query)
-		results = NewResults()
+		results = NewResults(sm.Options.ExpandLines)
 		err     error
 	)
 	Logger.Print("Searching")
@@ -231,16 +442,49 @@ func (sm *SearchManager) search() error {
 		return err
 	}
 
+	modes := []string{}
+	if sm.Options.ExpandLines {
+		modes = append(modes, "lines")
+	}
+	if exact := results.Len(); exact < MIN_EXACT_RESULTS {
+		err = sm.searchApproximate(query, results)
+		if err != nil {
+			return err
+		}
+		if results.Len() > exact {
+			modes = append(modes, "fuzzy")
+		}
+	}
+
 	Logger.Print("Found ", results.Len(), " results")
 
+	sorted := results.Sorted()
+	if sm.creatable(query) {
+		sorted = append(sorted, Result{Title: query, Create: true})
+	}
+	sm.setResults(sorted, modes)
+	return nil
+}
+
+// creatable returns whether a note titled by the query can be created, as it
+// is a valid title and no note has it already.
+func (sm *SearchManager) creatable(query string) bool {
+	if checkTitle(query) != nil {
+		return false
+	}
+	_, err := os.Stat(notePath(sm.Options.NotesDirectory, query))
+	return os.IsNotExist(err)
+}
+
+func (sm *SearchManager) setResults(results []Result, modes []string) {
 	sm.mutex.Lock()
-	sm.results = results.Sorted()
+	sm.results = results
 	if sm.selection >= len(sm.results) {
 		sm.selection = len(sm.results) - 1
 	}
+	sm.status = SearchStatus{false, time.Since(sm.started), modes, ""}
 	sm.mutex.Unlock()
 	sm.notify()
-	return nil
 }
 
 func (sm *SearchManager) Start() error {
@@ -255,9 +499,21 @@ func (sm *SearchManager) Start() error {
 	Logger.Print("Starting SearchManager")
 	for {
 		subscription.Wait()
+		sm.mutex.Lock()
+		sm.started = time.Now()
+		sm.status.Searching = true
+		sm.mutex.Unlock()
+		sm.notify()
 		err = sm.search()
 		if err != nil {
-			return err
+			// Searching fails for queries grep cannot parse, so the error is
+			// shown rather than ending the program.
+			Logger.Print("Error searching: ", err)
+			sm.mutex.Lock()
+			sm.status.Searching = false
+			sm.status.Error = err.Error()
+			sm.mutex.Unlock()
+			sm.notify()
 		}
 	}
 }
@@ -267,33 +523,116 @@ type SearchClient struct {
 }
 
 func (sc *SearchClient) Query() string {
-	return string(sc.sm.query)
+	sc.sm.mutex.RLock()
+	query := sc.sm.query.String()
+	sc.sm.mutex.RUnlock()
+	return query
 }
 
-func (sc *SearchClient) Results() (int, []string) {
+// QueryCursor returns the query with the position of the cursor in it, in
+// runes, read together so that the cursor is within the query.
+func (sc *SearchClient) QueryCursor() (string, int) {
+	sc.sm.mutex.RLock()
+	query := sc.sm.query.String()
+	cursor := sc.sm.query.Cursor()
+	sc.sm.mutex.RUnlock()
+	return query, cursor
+}
+
+func (sc *SearchClient) Results() (int, []Result) {
 	sc.sm.mutex.RLock()
 	selection := sc.sm.selection
-	results := make([]string, len(sc.sm.results))
+	results := make([]Result, len(sc.sm.results))
 	copy(results, sc.sm.results)
 	sc.sm.mutex.RUnlock()
 	return selection, results
 }
 
-func (sc *SearchClient) Append(c rune) {
-	sc.sm.query = append(sc.sm.query, c)
+// Status returns the status of the last search.
+func (sc *SearchClient) Status() SearchStatus {
+	sc.sm.mutex.RLock()
+	status := sc.sm.status
+	status.Modes = append([]string{}, status.Modes...)
+	sc.sm.mutex.RUnlock()
+	return status
+}
+
+// Related returns the title of the note whose related notes are being shown,
+// or "" if the results are for the query.
+func (sc *SearchClient) Related() string {
+	sc.sm.mutex.RLock()
+	related := sc.sm.related
+	sc.sm.mutex.RUnlock()
+	return related
+}
+
+// ToggleRelated replaces the results with the notes related to the selected
+// result, or goes back to the results for the query if they are already
+// shown.
+func (sc *SearchClient) ToggleRelated() {
+	sc.sm.mutex.Lock()
+	if sc.sm.related != "" {
+		sc.sm.related = ""
+	} else if sc.sm.selection != -1 &&
+		!sc.sm.results[sc.sm.selection].Create {
+		sc.sm.related = sc.sm.results[sc.sm.selection].Title
+		sc.sm.selection = -1
+	} else {
+		sc.sm.mutex.Unlock()
+		return
+	}
+	sc.sm.mutex.Unlock()
 	sc.sm.notify()
 	sc.sm.notifyQuery()
 }
 
-func (sc *SearchClient) Backspace() {
-	if len(sc.sm.query) == 0 {
-		return
+// Refresh searches again after notes were changed, forgetting marked results
+// and related notes of notes that no longer exist.
+func (sc *SearchClient) Refresh() {
+	sc.sm.mutex.Lock()
+	sc.sm.index = nil
+	exists := func(title string) bool {
+		_, err := os.Stat(notePath(sc.sm.Options.NotesDirectory, title))
+		return err == nil
+	}
+	marked := []Result{}
+	for _, result := range sc.sm.marked {
+		if exists(result.Title) {
+			marked = append(marked, result)
+		}
 	}
-	sc.sm.query = sc.sm.query[:len(sc.sm.query)-1]
+	sc.sm.marked = marked
+	if sc.sm.related != "" && !exists(sc.sm.related) {
+		sc.sm.related = ""
+	}
+	sc.sm.mutex.Unlock()
 	sc.sm.notify()
 	sc.sm.notifyQuery()
 }
 
+// Edit applies f to the query. A new search is started if the query was
+// changed.
+func (sc *SearchClient) Edit(f func(le *LineEditor)) {
+	sc.sm.mutex.Lock()
+	before := sc.sm.query.String()
+	f(sc.sm.query)
+	changed := sc.sm.query.String() != before
+	if changed {
+		sc.sm.related = ""
+	}
+	sc.sm.mutex.Unlock()
+	sc.sm.notify()
+	if changed {
+		sc.sm.notifyQuery()
+	}
+}
+
+func (sc *SearchClient) Insert(c rune) {
+	sc.Edit(func(le *LineEditor) {
+		le.Insert(c)
+	})
+}
+
 func (sc *SearchClient) SelectPrevious() {
 	sc.sm.mutex.Lock()
 	if sc.sm.selection > -1 {
@@ -312,25 +651,164 @@ func (sc *SearchClient) SelectNext() {
 	sc.sm.trigger.Notify()
 }
 
-func (sc *SearchClient) Select() {
+// moveSelection is MoveSelection for callers already holding the mutex.
+func (sm *SearchManager) moveSelection(delta int) {
+	selection := sm.selection + delta
+	if selection > len(sm.results)-1 {
+		selection = len(sm.results) - 1
+	}
+	if selection < -1 {
+		selection = -1
+	}
+	sm.selection = selection
+}
+
+// MoveSelection moves the selection by a number of results, stopping at the
+// query and at the last result.
+func (sc *SearchClient) MoveSelection(delta int) {
+	sc.sm.mutex.Lock()
+	sc.sm.moveSelection(delta)
+	sc.sm.mutex.Unlock()
+	sc.sm.trigger.Notify()
+}
+
+// ToggleMark marks the selected result to be opened along with the other
+// marked results, or unmarks it, then moves the selection by delta.
+func (sc *SearchClient) ToggleMark(delta int) {
+	sc.sm.mutex.Lock()
+	if sc.sm.selection == -1 || sc.sm.results[sc.sm.selection].Create {
+		sc.sm.mutex.Unlock()
+		return
+	}
+	result := sc.sm.results[sc.sm.selection]
+	marked := []Result{}
+	for _, m := range sc.sm.marked {
+		if m.key() != result.key() {
+			marked = append(marked, m)
+		}
+	}
+	if len(marked) == len(sc.sm.marked) {
+		marked = append(marked, result)
+	}
+	sc.sm.marked = marked
+	sc.sm.moveSelection(delta)
+	sc.sm.mutex.Unlock()
+	sc.sm.trigger.Notify()
+}
+
+// Marked returns the keys of the marked results.
+func (sc *SearchClient) Marked() map[string]bool {
 	sc.sm.mutex.RLock()
-	query := string(sc.sm.query)
+	marked := make(map[string]bool, len(sc.sm.marked))
+	for _, result := range sc.sm.marked {
+		marked[result.key()] = true
+	}
+	sc.sm.mutex.RUnlock()
+	return marked
+}
+
+// SelectFirst selects the first result, if any.
+func (sc *SearchClient) SelectFirst() {
+	sc.sm.mutex.Lock()
+	if len(sc.sm.results) > 0 {
+		sc.sm.selection = 0
+	}
+	sc.sm.mutex.Unlock()
+	sc.sm.trigger.Notify()
+}
+
+// SelectLast selects the last result, if any.
+func (sc *SearchClient) SelectLast() {
+	sc.sm.mutex.Lock()
+	if len(sc.sm.results) > 0 {
+		sc.sm.selection = len(sc.sm.results) - 1
+	}
+	sc.sm.mutex.Unlock()
+	sc.sm.trigger.Notify()
+}
+
+// SetSelection selects a result, or the query for -1.
+func (sc *SearchClient) SetSelection(selection int) {
+	sc.sm.mutex.Lock()
+	if selection >= -1 && selection < len(sc.sm.results) {
+		sc.sm.selection = selection
+	}
+	sc.sm.mutex.Unlock()
+	sc.sm.trigger.Notify()
+}
+
+// noteSelection returns the note to open for a result.
+func (sm *SearchManager) noteSelection(result Result) Selection {
+	return Selection{
+		notePath(sm.Options.NotesDirectory, result.Title), result.Line}
+}
+
+// Creating returns whether accepting creates a note rather than opening
+// notes, and the title of the note, which is "" when the empty query is
+// selected and a title must be entered. Accepting the query creates the note
+// of the create row, if there is one.
+func (sc *SearchClient) Creating() (string, bool) {
+	sc.sm.mutex.RLock()
+	defer sc.sm.mutex.RUnlock()
+	if len(sc.sm.marked) > 0 {
+		return "", false
+	}
+	if sc.sm.selection == -1 {
+		if sc.sm.query.Len() == 0 {
+			return "", true
+		}
+		// The results may still be those of an earlier query.
+		query := sc.sm.query.String()
+		n := len(sc.sm.results)
+		if n > 0 && sc.sm.results[n-1].Create &&
+			sc.sm.results[n-1].Title == query {
+			return query, true
+		}
+		return "", false
+	}
+	result := sc.sm.results[sc.sm.selection]
+	return result.Title, result.Create
+}
+
+// Create chooses a new note, which the editor creates.
+func (sc *SearchClient) Create(title string) {
+	sc.sm.Options.Selection <- Choice{
+		[]Selection{sc.sm.noteSelection(Result{Title: title})}, OPEN_EDITOR}
+}
+
+// Select chooses the marked results if any, otherwise the selected result, or
+// the note titled exactly by the query if the query is selected, to be opened
+// as given by how. Notes are created through Creating instead.
+func (sc *SearchClient) Select(how OpenMode) {
+	sc.sm.mutex.RLock()
+	query := sc.sm.query.String()
 	selection := sc.sm.selection
-	results := make([]string, len(sc.sm.results))
+	results := make([]Result, len(sc.sm.results))
 	copy(results, sc.sm.results)
+	marked := make([]Result, len(sc.sm.marked))
+	copy(marked, sc.sm.marked)
 	sc.sm.mutex.RUnlock()
-	if query == "" {
-		sc.sm.Options.Selection <- ""
+	if len(marked) > 0 {
+		selections := make([]Selection, len(marked))
+		for i, result := range marked {
+			selections[i] = sc.sm.noteSelection(result)
+		}
+		sc.sm.Options.Selection <- Choice{selections, how}
 		return
 	}
-	var title string
+	var result Result
 	if selection == -1 {
-		title = query
+		_, err := os.Stat(notePath(sc.sm.Options.NotesDirectory, query))
+		if query == "" || err != nil {
+			Logger.Print("No note titled by the query")
+			return
+		}
+		result = Result{Title: query}
 	} else {
-		title = results[selection]
+		result = results[selection]
 	}
-	notePath := path.Join(sc.sm.Options.NotesDirectory, title+".txt")
-	sc.sm.Options.Selection <- notePath
+	sc.sm.Options.Selection <- Choice{
+		[]Selection{sc.sm.noteSelection(result)}, how}
 }
 
 func (sc *SearchClient) Subscribe() Subscription {
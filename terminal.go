@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"syscall"
 
@@ -29,25 +30,86 @@ func SetSignal(termios *unix.Termios) {
 	termios.Lflag |= unix.ISIG
 }
 
-func WithTerminalAttributes(f func() error) error {
+// DisableInterrupt disables the INTR character, so that Ctrl-C is read as a
+// key rather than generating SIGINT, while Ctrl-Z and Ctrl-\ still signal.
+func DisableInterrupt(termios *unix.Termios) {
+	termios.Cc[unix.VINTR] = posixVDisable
+}
+
+// DEC private modes making the terminal report mouse button presses, releases
+// and wheel motion as SGR encoded CSI < b ; x ; y M sequences.
+const (
+	MOUSE_TRACKING_MODE = 1000
+	SGR_MOUSE_MODE      = 1006
+)
+
+func EnableMouse(w io.Writer) error {
+	ansi := ANSI{w}
+	err := ansi.DECSET(MOUSE_TRACKING_MODE)
+	if err != nil {
+		return err
+	}
+	return ansi.DECSET(SGR_MOUSE_MODE)
+}
+
+func DisableMouse(w io.Writer) error {
+	ansi := ANSI{w}
+	err := ansi.DECRST(SGR_MOUSE_MODE)
+	if err != nil {
+		return err
+	}
+	return ansi.DECRST(MOUSE_TRACKING_MODE)
+}
+
+// DEC private mode switching to the alternate screen buffer, saving the
+// cursor, and back, restoring the cursor and the screen as it was.
+const ALTERNATE_SCREEN_MODE = 1049
+
+func EnterAlternateScreen(w io.Writer) error {
+	return ANSI{w}.DECSET(ALTERNATE_SCREEN_MODE)
+}
+
+func ExitAlternateScreen(w io.Writer) error {
+	return ANSI{w}.DECRST(ALTERNATE_SCREEN_MODE)
+}
+
+// TerminalAttributes switches the terminal between raw mode and its original
+// attributes, as when suspending.
+type TerminalAttributes struct {
+	original *unix.Termios
+	raw      *unix.Termios
+}
+
+// Raw sets the terminal to raw mode.
+func (ta *TerminalAttributes) Raw() error {
+	return unix.IoctlSetTermios(syscall.Stdin, ioctlSetTermios, ta.raw)
+}
+
+// Restore restores the original terminal attributes.
+func (ta *TerminalAttributes) Restore() error {
+	return unix.IoctlSetTermios(syscall.Stdin, ioctlSetTermios, ta.original)
+}
+
+func WithTerminalAttributes(f func(ta *TerminalAttributes) error) error {
 	var err error
 	termios, err := unix.IoctlGetTermios(syscall.Stdin, ioctlGetTermios)
 	if err != nil {
 		return err
 	}
 	// Save terminal attributes.
-	original := &unix.Termios{}
-	*original = *termios
+	ta := &TerminalAttributes{&unix.Termios{}, termios}
+	*ta.original = *termios
 	SetRaw(termios)    // Set terminal to raw mode.
 	SetSignal(termios) // Allow keyboard signaling.
-	err = unix.IoctlSetTermios(syscall.Stdin, ioctlSetTermios, termios)
+	DisableInterrupt(termios)
+	err = ta.Raw()
 	if err != nil {
 		return err
 	}
 	defer func() {
 		r := recover()
 		// Restore terminal attributes.
-		err := unix.IoctlSetTermios(syscall.Stdin, ioctlSetTermios, original)
+		err := ta.Restore()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", err)
 		}
@@ -55,5 +117,5 @@ func WithTerminalAttributes(f func() error) error {
 			panic(r)
 		}
 	}()
-	return f()
+	return f(ta)
 }
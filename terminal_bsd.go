@@ -7,4 +7,6 @@ import "golang.org/x/sys/unix"
 const (
 	ioctlGetTermios = unix.TIOCGETA
 	ioctlSetTermios = unix.TIOCSETA
+	// The value of a special character disabling it.
+	posixVDisable = 0xff
 )
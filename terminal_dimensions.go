@@ -9,6 +9,13 @@ import (
 
 const MAX_TERMINAL_DIMENSION = 999
 
+// Kinds of cursor position reports requested, which the terminal answers in
+// order.
+const (
+	CPR_ORIGIN = iota
+	CPR_DIMENSIONS
+)
+
 type TerminalDimensionsManagerOptions struct {
 	Writer            io.Writer
 	WinchSubscription Subscription
@@ -18,7 +25,12 @@ type TerminalDimensionsManager struct {
 	Options       TerminalDimensionsManagerOptions
 	w             *bufio.Writer
 	width, height int
+	// The row of the screen the query is drawn on.
+	origin int
+	// Cursor position reports requested but not received yet.
+	pending       []int
 	trigger       *Trigger
+	originTrigger *Trigger
 	mutex         *sync.RWMutex
 }
 
@@ -28,6 +40,8 @@ func NewTerminalDimensionsManager(
 		options,
 		nil,
 		0, 0,
+		0, nil,
+		NewTrigger(),
 		NewTrigger(),
 		&sync.RWMutex{}}
 }
@@ -41,10 +55,19 @@ func (tdm *TerminalDimensionsManager) notify() {
 	tdm.trigger.Notify()
 }
 
+// requestCPR requests the position of the cursor, which is left on the query
+// line after drawing, and then of the bottom right corner of the screen.
 func (tdm *TerminalDimensionsManager) requestCPR() error {
 	Logger.Print("Requesting CPR")
+	tdm.mutex.Lock()
+	tdm.pending = append(tdm.pending, CPR_ORIGIN, CPR_DIMENSIONS)
+	tdm.mutex.Unlock()
 	ansi := ANSI{tdm.w}
 	var err error
+	err = ansi.DSR()
+	if err != nil {
+		return err
+	}
 	err = ansi.SCP()
 	if err != nil {
 		return err
@@ -76,6 +99,9 @@ func (tdm *TerminalDimensionsManager) Start() error {
 		return fmt.Errorf("no WinchSubscription")
 	}
 	tdm.w = bufio.NewWriter(tdm.Options.Writer)
+	subscription := NewAnySubscription(
+		tdm.Options.WinchSubscription,
+		tdm.originTrigger.Subscribe())
 	var err error
 	Logger.Print("Starting TerminalDimensionsManager")
 	for {
@@ -83,7 +109,7 @@ func (tdm *TerminalDimensionsManager) Start() error {
 		if err != nil {
 			return err
 		}
-		tdm.Options.WinchSubscription.Wait()
+		subscription.Wait()
 	}
 }
 
@@ -101,6 +127,27 @@ func (tdc *TerminalDimensionsClient) SetDimensions(n, m int) {
 	tdc.tdm.notify()
 }
 
+// ReportCursorPosition handles a cursor position report, in answer to the
+// oldest request not answered yet.
+func (tdc *TerminalDimensionsClient) ReportCursorPosition(n, m int) {
+	tdc.tdm.mutex.Lock()
+	if len(tdc.tdm.pending) == 0 {
+		tdc.tdm.mutex.Unlock()
+		Logger.Print("Unexpected CPR ", n, m)
+		return
+	}
+	kind := tdc.tdm.pending[0]
+	tdc.tdm.pending = tdc.tdm.pending[1:]
+	if kind == CPR_ORIGIN {
+		tdc.tdm.origin = n
+		Logger.Print("New origin := ", n)
+	}
+	tdc.tdm.mutex.Unlock()
+	if kind == CPR_DIMENSIONS {
+		tdc.SetDimensions(n, m)
+	}
+}
+
 func (tdc *TerminalDimensionsClient) Dimensions() (int, int) {
 	tdc.tdm.mutex.RLock()
 	width, height := tdc.tdm.width, tdc.tdm.height
@@ -108,6 +155,21 @@ func (tdc *TerminalDimensionsClient) Dimensions() (int, int) {
 	return width, height
 }
 
+// Origin returns the row of the screen the query is drawn on, starting at 1,
+// or 0 if it is not known yet.
+func (tdc *TerminalDimensionsClient) Origin() int {
+	tdc.tdm.mutex.RLock()
+	origin := tdc.tdm.origin
+	tdc.tdm.mutex.RUnlock()
+	return origin
+}
+
+// RequestOrigin requests the row of the query again, after drawing may have
+// scrolled the screen.
+func (tdc *TerminalDimensionsClient) RequestOrigin() {
+	tdc.tdm.originTrigger.Notify()
+}
+
 func (tdc *TerminalDimensionsClient) Subscribe() Subscription {
 	return tdc.tdm.trigger.Subscribe()
 }
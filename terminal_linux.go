@@ -5,4 +5,6 @@ import "golang.org/x/sys/unix"
 const (
 	ioctlGetTermios = unix.TCGETS
 	ioctlSetTermios = unix.TCSETS
+	// The value of a special character disabling it.
+	posixVDisable = 0
 )
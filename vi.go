@@ -0,0 +1,194 @@
+package main
+
+import "unicode"
+
+// Editing modes of the query line in vi mode.
+const (
+	MODE_INSERT = "INSERT"
+	MODE_NORMAL = "NORMAL"
+)
+
+// NextWordStart returns the position of the start of the next word after the
+// cursor.
+func (le *LineEditor) NextWordStart() int {
+	i := le.cursor
+	if i < len(le.runes) && isWordRune(le.runes[i]) {
+		for i < len(le.runes) && isWordRune(le.runes[i]) {
+			i++
+		}
+	} else if i < len(le.runes) && !unicode.IsSpace(le.runes[i]) {
+		i++
+	}
+	for i < len(le.runes) && unicode.IsSpace(le.runes[i]) {
+		i++
+	}
+	return i
+}
+
+// LastWordRune returns the position of the last character of the word ending
+// after the cursor, where vi's e motion moves.
+func (le *LineEditor) LastWordRune() int {
+	i := le.cursor + 1
+	for i < len(le.runes) && !isWordRune(le.runes[i]) {
+		i++
+	}
+	for i < len(le.runes) && isWordRune(le.runes[i]) {
+		i++
+	}
+	if i > len(le.runes) {
+		return len(le.runes)
+	}
+	return i - 1
+}
+
+// VI_MOTIONS give the position each motion moves the cursor to.
+var VI_MOTIONS = map[rune]func(le *LineEditor) int{
+	'h': func(le *LineEditor) int { return le.Cursor() - 1 },
+	'l': func(le *LineEditor) int { return le.Cursor() + 1 },
+	'w': (*LineEditor).NextWordStart,
+	'b': (*LineEditor).WordStart,
+	'e': (*LineEditor).LastWordRune,
+	'0': func(le *LineEditor) int { return 0 },
+	'^': func(le *LineEditor) int { return 0 },
+	'$': (*LineEditor).Len,
+}
+
+// clampNormal keeps the cursor on a character, as it cannot be after the end
+// of the line in normal mode.
+func clampNormal(le *LineEditor) {
+	if le.Len() > 0 && le.Cursor() >= le.Len() {
+		le.MoveTo(le.Len() - 1)
+	}
+}
+
+func (im *InputManager) setMode(mode string) {
+	im.mutex.Lock()
+	im.mode = mode
+	im.operator = 0
+	im.mutex.Unlock()
+	im.notify()
+}
+
+// handleViEscape switches to normal mode, or cancels a pending operator.
+func (im *InputManager) handleViEscape() {
+	if im.Mode() == MODE_INSERT {
+		im.Options.Search.Edit((*LineEditor).Left)
+	}
+	im.setMode(MODE_NORMAL)
+}
+
+// cancelViOperator cancels a pending operator, returning whether there was
+// one, so that Escape only aborts when there is nothing to cancel.
+func (im *InputManager) cancelViOperator() bool {
+	im.mutex.Lock()
+	operator := im.operator
+	im.operator = 0
+	im.mutex.Unlock()
+	return operator != 0
+}
+
+// handleViOperator applies an operator to the text between the cursor and
+// where a motion moves it.
+func (im *InputManager) handleViOperator(operator, motion rune) {
+	search := im.Options.Search
+	if motion == operator {
+		// The operator applies to the whole line, as in dd or cc.
+		search.Edit(func(le *LineEditor) {
+			le.Kill(0, le.Len())
+		})
+	} else {
+		f, ok := VI_MOTIONS[motion]
+		if !ok {
+			im.setMode(MODE_NORMAL)
+			return
+		}
+		// The e motion includes the character it moves to.
+		inclusive := motion == 'e'
+		if operator == 'c' && motion == 'w' {
+			// Like vi, cw changes to the end of the word.
+			f = (*LineEditor).WordEnd
+		}
+		search.Edit(func(le *LineEditor) {
+			to := f(le)
+			if inclusive {
+				to++
+			}
+			le.Kill(le.Cursor(), to)
+		})
+	}
+	if operator == 'c' {
+		im.setMode(MODE_INSERT)
+		return
+	}
+	search.Edit(clampNormal)
+	im.setMode(MODE_NORMAL)
+}
+
+// handleViNormal handles a character typed in normal mode.
+func (im *InputManager) handleViNormal(c rune) {
+	search := im.Options.Search
+	im.mutex.RLock()
+	operator := im.operator
+	im.mutex.RUnlock()
+	if operator != 0 {
+		im.handleViOperator(operator, c)
+		return
+	}
+	if f, ok := VI_MOTIONS[c]; ok {
+		search.Edit(func(le *LineEditor) {
+			le.MoveTo(f(le))
+			clampNormal(le)
+		})
+		return
+	}
+	switch c {
+	case 'd', 'c':
+		im.mutex.Lock()
+		im.operator = c
+		im.mutex.Unlock()
+	case 'D':
+		im.handleViOperator('d', '$')
+	case 'C':
+		im.handleViOperator('c', '$')
+	case 'x':
+		search.Edit(func(le *LineEditor) {
+			// Killing an empty range would replace the killed text.
+			if le.Cursor() < le.Len() {
+				le.Kill(le.Cursor(), le.Cursor()+1)
+			}
+			clampNormal(le)
+		})
+	case 'X':
+		search.Edit(func(le *LineEditor) {
+			if le.Cursor() > 0 {
+				le.Kill(le.Cursor()-1, le.Cursor())
+			}
+		})
+	case 'p':
+		search.Edit(func(le *LineEditor) {
+			le.Right()
+			le.Yank()
+			le.Left()
+		})
+	case 'P':
+		search.Edit(func(le *LineEditor) {
+			le.Yank()
+			le.Left()
+		})
+	case 'i':
+		im.setMode(MODE_INSERT)
+	case 'a':
+		search.Edit((*LineEditor).Right)
+		im.setMode(MODE_INSERT)
+	case 'I':
+		search.Edit((*LineEditor).Home)
+		im.setMode(MODE_INSERT)
+	case 'A':
+		search.Edit((*LineEditor).End)
+		im.setMode(MODE_INSERT)
+	case 'j':
+		search.SelectNext()
+	case 'k':
+		search.SelectPrevious()
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// WIDE_RANGES are the ranges of East Asian wide and fullwidth characters,
+// and of emoji, which take two cells of the terminal.
+var WIDE_RANGES = []struct{ first, last rune }{
+	{0x1100, 0x115F},   // Hangul Jamo initial consonants
+	{0x231A, 0x231B},   // Watch, hourglass
+	{0x2329, 0x232A},   // Angle brackets
+	{0x23E9, 0x23EC},   // Media controls
+	{0x23F0, 0x23F0},   // Alarm clock
+	{0x23F3, 0x23F3},   // Hourglass with flowing sand
+	{0x25FD, 0x25FE},   // Medium small squares
+	{0x2614, 0x2615},   // Umbrella, hot beverage
+	{0x2648, 0x2653},   // Zodiac signs
+	{0x267F, 0x267F},   // Wheelchair
+	{0x2693, 0x2693},   // Anchor
+	{0x26A1, 0x26A1},   // High voltage
+	{0x26AA, 0x26AB},   // Medium circles
+	{0x26BD, 0x26BE},   // Soccer ball, baseball
+	{0x26C4, 0x26C5},   // Snowman, sun behind cloud
+	{0x26CE, 0x26CE},   // Ophiuchus
+	{0x26D4, 0x26D4},   // No entry
+	{0x26EA, 0x26EA},   // Church
+	{0x26F2, 0x26F3},   // Fountain, flag in hole
+	{0x26F5, 0x26F5},   // Sailboat
+	{0x26FA, 0x26FA},   // Tent
+	{0x26FD, 0x26FD},   // Fuel pump
+	{0x2705, 0x2705},   // Check mark button
+	{0x270A, 0x270B},   // Raised fists
+	{0x2728, 0x2728},   // Sparkles
+	{0x274C, 0x274C},   // Cross mark
+	{0x274E, 0x274E},   // Cross mark button
+	{0x2753, 0x2755},   // Question and exclamation marks
+	{0x2757, 0x2757},   // Exclamation mark
+	{0x2795, 0x2797},   // Plus, minus, division
+	{0x27B0, 0x27B0},   // Curly loop
+	{0x27BF, 0x27BF},   // Double curly loop
+	{0x2B1B, 0x2B1C},   // Large squares
+	{0x2B50, 0x2B50},   // Star
+	{0x2B55, 0x2B55},   // Hollow red circle
+	{0x2E80, 0x303E},   // CJK radicals to CJK symbols and punctuation
+	{0x3041, 0x33FF},   // Hiragana to CJK compatibility
+	{0x3400, 0x4DBF},   // CJK unified ideographs extension A
+	{0x4E00, 0x9FFF},   // CJK unified ideographs
+	{0xA000, 0xA4CF},   // Yi
+	{0xA960, 0xA97F},   // Hangul Jamo extended A
+	{0xAC00, 0xD7A3},   // Hangul syllables
+	{0xF900, 0xFAFF},   // CJK compatibility ideographs
+	{0xFE10, 0xFE19},   // Vertical forms
+	{0xFE30, 0xFE6F},   // CJK compatibility forms, small form variants
+	{0xFF00, 0xFF60},   // Fullwidth forms
+	{0xFFE0, 0xFFE6},   // Fullwidth signs
+	{0x16FE0, 0x16FE4}, // Ideographic symbols
+	{0x17000, 0x18CFF}, // Tangut
+	{0x1B000, 0x1B2FF}, // Kana supplement to Nushu
+	{0x1F004, 0x1F004}, // Mahjong tile
+	{0x1F0CF, 0x1F0CF}, // Playing card
+	{0x1F18E, 0x1F18E}, // AB button
+	{0x1F191, 0x1F19A}, // Squared words
+	{0x1F200, 0x1F2FF}, // Enclosed ideographic supplement
+	{0x1F300, 0x1F64F}, // Pictographs and emoticons
+	{0x1F680, 0x1F6FF}, // Transport and map symbols
+	{0x1F7E0, 0x1F7EB}, // Large colored circles and squares
+	{0x1F90C, 0x1F9FF}, // Supplemental symbols and pictographs
+	{0x1FA70, 0x1FAFF}, // Symbols and pictographs extended A
+	{0x20000, 0x2FFFD}, // CJK unified ideographs extensions B to F
+	{0x30000, 0x3FFFD}, // CJK unified ideographs extension G
+}
+
+const (
+	ZERO_WIDTH_JOINER    = 0x200D
+	EMOJI_PRESENTATION   = 0xFE0F
+	REGIONAL_INDICATOR_A = 0x1F1E6
+	REGIONAL_INDICATOR_Z = 0x1F1FF
+	SKIN_TONE_MODIFIER_1 = 0x1F3FB
+	SKIN_TONE_MODIFIER_6 = 0x1F3FF
+)
+
+// runeWidth returns the number of cells a character takes on its own.
+func runeWidth(c rune) int {
+	switch {
+	case c < 0x20 || (c >= 0x7F && c < 0xA0):
+		return 0
+	case unicode.In(c, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	case c >= 0x1160 && c <= 0x11FF:
+		// Hangul Jamo medial vowels and final consonants combine with the
+		// initial consonant.
+		return 0
+	case isRegionalIndicator(c):
+		return 2
+	}
+	for _, r := range WIDE_RANGES {
+		if c < r.first {
+			break
+		}
+		if c <= r.last {
+			return 2
+		}
+	}
+	return 1
+}
+
+func isRegionalIndicator(c rune) bool {
+	return c >= REGIONAL_INDICATOR_A && c <= REGIONAL_INDICATOR_Z
+}
+
+// extends returns whether a character belongs to the grapheme cluster of the
+// characters before it.
+func extends(c rune) bool {
+	return (runeWidth(c) == 0 && c >= 0x20) ||
+		(c >= SKIN_TONE_MODIFIER_1 && c <= SKIN_TONE_MODIFIER_6)
+}
+
+// graphemes splits a string into grapheme clusters, the characters as seen by
+// the user: a base character with the combining marks, variation selectors
+// and modifiers following it, emoji joined by zero width joiners, and pairs
+// of regional indicators forming flags. This is a simplification of the
+// rules of Unicode Standard Annex #29 covering text as found in notes.
+func graphemes(s string) []string {
+	clusters := []string{}
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		j := i + 1
+		if isRegionalIndicator(runes[i]) && j < len(runes) &&
+			isRegionalIndicator(runes[j]) {
+			j++
+		}
+		for j < len(runes) {
+			if runes[j-1] == ZERO_WIDTH_JOINER || extends(runes[j]) {
+				j++
+				continue
+			}
+			break
+		}
+		clusters = append(clusters, string(runes[i:j]))
+		i = j
+	}
+	return clusters
+}
+
+// clusterWidth returns the number of cells a grapheme cluster takes, which is
+// the width of its base character unless it is presented as an emoji.
+func clusterWidth(cluster string) int {
+	width := 0
+	for i, c := range cluster {
+		if i == 0 {
+			width = runeWidth(c)
+		} else if c == EMOJI_PRESENTATION && width == 1 {
+			width = 2
+		}
+	}
+	return width
+}
+
+// stringWidth returns the number of cells a string takes on the terminal.
+func stringWidth(s string) int {
+	width := 0
+	for _, cluster := range graphemes(s) {
+		width += clusterWidth(cluster)
+	}
+	return width
+}
+
+// truncate shortens a string to fit in a number of cells, ending it with an
+// ellipsis if it does not fit. Wide characters are never split.
+func truncate(s string, width int) string {
+	if stringWidth(s) <= width {
+		return s
+	}
+	if width < 1 {
+		return ""
+	}
+	var b strings.Builder
+	used := 0
+	for _, cluster := range graphemes(s) {
+		w := clusterWidth(cluster)
+		// Leave a cell for the ellipsis.
+		if used+w > width-1 {
+			break
+		}
+		b.WriteString(cluster)
+		used += w
+	}
+	b.WriteString("…")
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStringWidth(t *testing.T) {
+	tests := []struct {
+		name  string
+		s     string
+		width int
+	}{
+		{"empty", "", 0},
+		{"ascii", "abc", 3},
+		{"wide", "日本語", 6},
+		{"mixed", "a日b", 4},
+		{"fullwidth", "ＡＢ", 4},
+		{"hangul", "한국", 4},
+		{"hangul jamo", "각", 2},
+		{"combining marks", "éẹ̈", 2},
+		{"zwj sequence", "👨‍👩‍👧", 2},
+		{"zwj sequences", "👨‍👩‍👧👨‍💻", 4},
+		{"skin tone", "👍🏽", 2},
+		{"flag", "🇯🇵", 2},
+		{"flags", "🇯🇵🇫🇷", 4},
+		{"emoji presentation", "☺️", 2},
+		{"text presentation", "☺", 1},
+		{"control", "a\x1bb", 2},
+	}
+	for _, test := range tests {
+		if width := stringWidth(test.s); width != test.width {
+			t.Errorf("%s: got %d, want %d", test.name, width, test.width)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"abc", 3, "abc"},
+		{"abc", 5, "abc"},
+		{"abcd", 3, "ab…"},
+		{"abc", 1, "…"},
+		{"abc", 0, ""},
+		{"abc", -1, ""},
+		{"日本語", 6, "日本語"},
+		{"日本語", 5, "日本…"},
+		// The wide character at the cut is dropped rather than split.
+		{"日本語", 4, "日…"},
+		{"a日本", 3, "a…"},
+		{"日本", 2, "…"},
+		{"ééé", 2, "é…"},
+		{"ab👨‍👩‍👧", 3, "ab…"},
+		{"👨‍👩‍👧ab", 3, "👨‍👩‍👧…"},
+		{"👨‍👩‍👧ab", 2, "…"},
+		{"🇯🇵🇫🇷", 3, "🇯🇵…"},
+	}
+	for _, test := range tests {
+		if s := truncate(test.s, test.width); s != test.want {
+			t.Errorf("%q in %d: got %q, want %q",
+				test.s, test.width, s, test.want)
+		}
+	}
+}
+
+func TestTruncateSpans(t *testing.T) {
+	plain := Style{}
+	bold := Style{1}
+	tests := []struct {
+		name  string
+		spans []span
+		width int
+		want  []span
+	}{
+		{"fits", []span{{"ab", plain}, {"cd", bold}}, 4,
+			[]span{{"ab", plain}, {"cd", bold}}},
+		{"cut in second", []span{{"ab", plain}, {"cde", bold}}, 4,
+			[]span{{"ab", plain}, {"c…", bold}}},
+		{"cut at boundary", []span{{"ab", plain}, {"cd", bold}}, 3,
+			[]span{{"ab…", plain}}},
+		{"cut in first", []span{{"abc", plain}, {"d", bold}}, 3,
+			[]span{{"ab…", plain}}},
+		{"only ellipsis", []span{{"ab", plain}, {"cd", bold}}, 1,
+			[]span{{"…", plain}}},
+		{"no room", []span{{"ab", plain}}, 0, nil},
+		{"wide", []span{{"日", plain}, {"本語", bold}}, 5,
+			[]span{{"日", plain}, {"本…", bold}}},
+		{"cut at wide", []span{{"日", plain}, {"本語", bold}}, 4,
+			[]span{{"日…", plain}}},
+		{"combining marks", []span{{"é", plain}, {"ée", bold}}, 2,
+			[]span{{"é…", plain}}},
+		{"zwj sequence", []span{{"a", plain}, {"👨‍👩‍👧bc", bold}}, 4,
+			[]span{{"a", plain}, {"👨‍👩‍👧…", bold}}},
+	}
+	for _, test := range tests {
+		spans := truncateSpans(test.spans, test.width)
+		if !reflect.DeepEqual(spans, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, spans, test.want)
+		}
+	}
+}